@@ -0,0 +1,34 @@
+package crossposter
+
+import "time"
+
+// Checkpoints of latest published dates tracked separately for each source
+type Checkpoints struct {
+	lastUpdate time.Time
+	dates      map[string]time.Time
+}
+
+// NewCheckpoints return checkpoints starting from last update
+func NewCheckpoints(lastUpdate time.Time) *Checkpoints {
+	return &Checkpoints{lastUpdate: lastUpdate, dates: make(map[string]time.Time)}
+}
+
+// Get checkpoint of source or last update if nothing published from it yet
+func (c *Checkpoints) Get(source string) time.Time {
+	if date, ok := c.dates[source]; ok {
+		return date
+	}
+	return c.lastUpdate
+}
+
+// IsNew check that date is after checkpoint of source
+func (c *Checkpoints) IsNew(source string, date time.Time) bool {
+	return date.After(c.Get(source))
+}
+
+// Update move checkpoint of source forward to date
+func (c *Checkpoints) Update(source string, date time.Time) {
+	if c.IsNew(source, date) {
+		c.dates[source] = date
+	}
+}
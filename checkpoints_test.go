@@ -0,0 +1,46 @@
+package crossposter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckpointsSkewedSources(t *testing.T) {
+	lastUpdate := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	ahead := lastUpdate.Add(time.Hour)
+	behind := lastUpdate.Add(-time.Hour)
+
+	tests := []struct {
+		name   string
+		source string
+		date   time.Time
+		want   bool
+	}{
+		{"ahead source publishes future item", "ahead", ahead.Add(10 * time.Minute), true},
+		{"behind source keeps own checkpoint", "behind", lastUpdate.Add(time.Minute), true},
+		{"behind source item older than last update", "behind", behind, false},
+		{"ahead source repeated item", "ahead", ahead.Add(10 * time.Minute), false},
+		{"behind source item before ahead checkpoint", "behind", lastUpdate.Add(2 * time.Minute), true},
+		{"behind source repeated item", "behind", lastUpdate.Add(2 * time.Minute), false},
+		{"ahead source later item", "ahead", ahead.Add(20 * time.Minute), true},
+		{"unknown source starts from last update", "other", lastUpdate, false},
+	}
+
+	checkpoints := NewCheckpoints(lastUpdate)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := checkpoints.IsNew(tt.source, tt.date)
+			if got != tt.want {
+				t.Errorf("IsNew(%s, %v) = %v, want %v", tt.source, tt.date, got, tt.want)
+			}
+			checkpoints.Update(tt.source, tt.date)
+		})
+	}
+
+	if got := checkpoints.Get("behind"); !got.Equal(lastUpdate.Add(2 * time.Minute)) {
+		t.Errorf("checkpoint of behind source = %v", got)
+	}
+	if got := checkpoints.Get("ahead"); !got.Equal(ahead.Add(20 * time.Minute)) {
+		t.Errorf("checkpoint of ahead source = %v", got)
+	}
+}
@@ -0,0 +1,351 @@
+package email
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/n0madic/crossposter"
+	log "github.com/sirupsen/logrus"
+)
+
+const maxAttachmentSize = 10 << 20
+
+// httpClient for downloading of attachments
+var httpClient = &http.Client{Timeout: time.Minute}
+
+// Email entity
+type Email struct {
+	entity   *crossposter.Entity
+	host     string
+	port     string
+	security string
+	auth     smtp.Auth
+	from     string
+	subject  string
+	digest   time.Duration
+	queue    []crossposter.Post
+	mutex    sync.Mutex
+}
+
+type attachment struct {
+	name        string
+	contentType string
+	contentID   string
+	data        []byte
+}
+
+func init() {
+	crossposter.AddEntity("email", New)
+}
+
+// New return email entity
+func New(entity crossposter.Entity) (crossposter.EntityInterface, error) {
+	email := &Email{
+		entity:   &entity,
+		host:     entity.Options["host"],
+		port:     entity.Options["port"],
+		security: strings.ToLower(entity.Options["security"]),
+		from:     entity.Options["from"],
+		subject:  entity.Options["subject"],
+	}
+	if email.host == "" {
+		return nil, fmt.Errorf("SMTP host not specified")
+	}
+	switch email.security {
+	case "", "starttls":
+		email.security = "starttls"
+		if email.port == "" {
+			email.port = "587"
+		}
+	case "tls":
+		if email.port == "" {
+			email.port = "465"
+		}
+	case "none":
+		if email.port == "" {
+			email.port = "25"
+		}
+	default:
+		return nil, fmt.Errorf("unsupported SMTP security: %s", email.security)
+	}
+	if entity.Options["user"] != "" {
+		email.auth = smtp.PlainAuth("", entity.Options["user"], entity.Options["password"], email.host)
+		if email.from == "" {
+			email.from = entity.Options["user"]
+		}
+	}
+	if email.from == "" {
+		return nil, fmt.Errorf("sender address not specified")
+	}
+	if entity.Options["digest"] != "" {
+		minutes, err := strconv.Atoi(entity.Options["digest"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid digest interval: %v", err)
+		}
+		email.digest = time.Duration(minutes) * time.Minute
+	}
+	return email, nil
+}
+
+// Get not implemented
+func (email *Email) Get(lastUpdate time.Time) {
+	defer crossposter.WaitGroup.Done()
+}
+
+// Post send post by email or queue it for digest
+func (email *Email) Post(post crossposter.Post) {
+	if email.digest == 0 {
+		email.send([]crossposter.Post{post})
+		return
+	}
+
+	email.mutex.Lock()
+	defer email.mutex.Unlock()
+	if len(email.queue) == 0 {
+		time.AfterFunc(email.digest, email.flush)
+	}
+	email.queue = append(email.queue, post)
+}
+
+// Handler not implemented
+func (email *Email) Handler(w http.ResponseWriter, r *http.Request) {}
+
+// flush send queued posts as digest
+func (email *Email) flush() {
+	email.mutex.Lock()
+	posts := email.queue
+	email.queue = nil
+	email.mutex.Unlock()
+
+	if len(posts) > 0 {
+		email.send(posts)
+	}
+}
+
+// send posts in one message to each destination
+func (email *Email) send(posts []crossposter.Post) {
+	emailLogger := log.WithFields(log.Fields{"destinations": email.entity.Destinations, "type": email.entity.Type})
+
+	var htmlBody, textBody strings.Builder
+	var attachments []attachment
+	for i, post := range posts {
+		if i > 0 {
+			htmlBody.WriteString("\n<hr>\n")
+			textBody.WriteString("\n\n----------\n\n")
+		}
+		fullText := post.FullText()
+		htmlBody.WriteString(strings.ReplaceAll(fullText, "\n", "<br>\n"))
+		textBody.WriteString(htmlToText(fullText))
+		for _, attach := range post.Attachments {
+			a, err := download(attach)
+			if err != nil {
+				emailLogger.Warnf("Can't download attachment %s: %s", attach, err)
+				textBody.WriteString("\n" + attach)
+				continue
+			}
+			if strings.HasPrefix(a.contentType, "image/") {
+				a.contentID = fmt.Sprintf("%d.%d@crossposter", time.Now().UnixNano(), len(attachments))
+				htmlBody.WriteString(fmt.Sprintf("<br>\n<img src=\"cid:%s\" alt=\"%s\">", a.contentID, html.EscapeString(a.name)))
+			}
+			attachments = append(attachments, a)
+		}
+	}
+
+	subject := email.subject
+	if len(posts) == 1 {
+		title := posts[0].Title
+		if title == "" {
+			title = truncateLine(htmlToText(posts[0].Text), 78)
+		}
+		subject = strings.TrimSpace(subject + " " + title)
+	} else {
+		subject = strings.TrimSpace(fmt.Sprintf("%s Digest: %d posts", subject, len(posts)))
+	}
+
+	// Separate message for each recipient to not disclose addresses to others
+	for _, to := range email.entity.Destinations {
+		rcptLogger := emailLogger.WithField("to", to)
+		msg, err := email.compose(to, subject, htmlBody.String(), textBody.String(), attachments)
+		if err != nil {
+			rcptLogger.Error(err)
+			continue
+		}
+		err = email.sendMail(to, msg)
+		if err != nil {
+			rcptLogger.Error(err)
+		} else {
+			rcptLogger.Printf("Sent email: %s", subject)
+		}
+	}
+}
+
+// compose MIME message with HTML and plain text alternatives,
+// inline images and other attachments
+func (email *Email) compose(to, subject, htmlBody, textBody string, attachments []attachment) ([]byte, error) {
+	var msg bytes.Buffer
+	hostname, _ := os.Hostname()
+	fmt.Fprintf(&msg, "From: %s\r\n", email.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: <%d@%s>\r\n", time.Now().UnixNano(), hostname)
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+
+	mixed := multipart.NewWriter(&msg)
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mixed.Boundary())
+
+	var related bytes.Buffer
+	relatedWriter := multipart.NewWriter(&related)
+	relatedPart, err := mixed.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {fmt.Sprintf("multipart/related; boundary=%s", relatedWriter.Boundary())},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var alternative bytes.Buffer
+	alternativeWriter := multipart.NewWriter(&alternative)
+	alternativePart, err := relatedWriter.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {fmt.Sprintf("multipart/alternative; boundary=%s", alternativeWriter.Boundary())},
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = writeText(alternativeWriter, "text/plain", textBody)
+	if err != nil {
+		return nil, err
+	}
+	err = writeText(alternativeWriter, "text/html", "<html><body>\n"+htmlBody+"\n</body></html>")
+	if err != nil {
+		return nil, err
+	}
+	alternativeWriter.Close()
+	alternativePart.Write(alternative.Bytes())
+
+	for _, a := range attachments {
+		if a.contentID != "" {
+			err = writeAttachment(relatedWriter, a)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	relatedWriter.Close()
+	relatedPart.Write(related.Bytes())
+
+	for _, a := range attachments {
+		if a.contentID == "" {
+			err = writeAttachment(mixed, a)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	mixed.Close()
+
+	return msg.Bytes(), nil
+}
+
+// sendMail deliver message to recipient over SMTP
+func (email *Email) sendMail(to string, msg []byte) error {
+	addr := net.JoinHostPort(email.host, email.port)
+	tlsConfig := &tls.Config{ServerName: email.host}
+
+	var client *smtp.Client
+	if email.security == "tls" {
+		conn, err := tls.Dial("tcp", addr, tlsConfig)
+		if err != nil {
+			return err
+		}
+		client, err = smtp.NewClient(conn, email.host)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+	} else {
+		var err error
+		client, err = smtp.Dial(addr)
+		if err != nil {
+			return err
+		}
+	}
+	defer client.Close()
+
+	if email.security == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("SMTP server %s does not support STARTTLS", email.host)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if email.auth != nil {
+		if err := client.Auth(email.auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(email.from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(msg)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// download attachment content
+func download(url string) (attachment, error) {
+	a := attachment{name: path.Base(strings.SplitN(url, "?", 2)[0])}
+
+	res, err := httpClient.Get(url)
+	if err != nil {
+		return a, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return a, fmt.Errorf("bad status: %s", res.Status)
+	}
+
+	a.data, err = ioutil.ReadAll(io.LimitReader(res.Body, maxAttachmentSize+1))
+	if err != nil {
+		return a, err
+	}
+	if len(a.data) > maxAttachmentSize {
+		return a, fmt.Errorf("attachment is too large")
+	}
+
+	a.contentType, _, _ = mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if a.contentType == "" || a.contentType == "application/octet-stream" {
+		a.contentType, _, _ = mime.ParseMediaType(http.DetectContentType(a.data))
+	}
+	return a, nil
+}
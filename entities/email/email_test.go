@@ -0,0 +1,159 @@
+package email
+
+import (
+	"bufio"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/n0madic/crossposter"
+)
+
+// message received by fake SMTP server
+type message struct {
+	from string
+	rcpt []string
+	data string
+}
+
+// fakeSMTP accept connections and record delivered messages
+type fakeSMTP struct {
+	listener net.Listener
+	messages []message
+	mutex    sync.Mutex
+	wg       sync.WaitGroup
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeSMTP{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.wg.Add(1)
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (server *fakeSMTP) serve(conn net.Conn) {
+	defer server.wg.Done()
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost ESMTP")
+
+	var msg message
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO", "HELO":
+			text.PrintfLine("250 localhost")
+		case "MAIL":
+			msg = message{from: addressOf(line)}
+			text.PrintfLine("250 OK")
+		case "RCPT":
+			msg.rcpt = append(msg.rcpt, addressOf(line))
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 Go ahead")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.data = string(data)
+			server.mutex.Lock()
+			server.messages = append(server.messages, msg)
+			server.mutex.Unlock()
+			text.PrintfLine("250 OK")
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+// close server and wait for finished sessions
+func (server *fakeSMTP) close() []message {
+	server.listener.Close()
+	server.wg.Wait()
+	return server.messages
+}
+
+func addressOf(line string) string {
+	start := strings.Index(line, "<")
+	end := strings.LastIndex(line, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return line[start+1 : end]
+}
+
+func TestPostSendsMessagePerRecipient(t *testing.T) {
+	server := newFakeSMTP(t)
+	host, port, _ := net.SplitHostPort(server.listener.Addr().String())
+
+	entity, err := New(crossposter.Entity{
+		Type: "email",
+		Options: map[string]string{
+			"host":     host,
+			"port":     port,
+			"security": "none",
+			"from":     "bot@example.com",
+			"subject":  "[News]",
+		},
+		Destinations: []string{"alice@example.com", "bob@example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entity.Post(crossposter.Post{
+		URL:   "https://example.com/post",
+		Title: "Hello",
+		Text:  "World",
+	})
+	messages := server.close()
+
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(messages))
+	}
+	for i, to := range []string{"alice@example.com", "bob@example.com"} {
+		msg := messages[i]
+		if msg.from != "bot@example.com" {
+			t.Errorf("MAIL FROM = %q", msg.from)
+		}
+		if len(msg.rcpt) != 1 || msg.rcpt[0] != to {
+			t.Errorf("RCPT TO = %v, want [%s]", msg.rcpt, to)
+		}
+
+		reader := textproto.NewReader(bufio.NewReader(strings.NewReader(msg.data)))
+		header, err := reader.ReadMIMEHeader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := header.Values("To"); len(got) != 1 || got[0] != to {
+			t.Errorf("To header = %v, want %s", got, to)
+		}
+		if got := header.Get("Subject"); got != "[News] Hello" {
+			t.Errorf("Subject header = %q", got)
+		}
+		if !strings.Contains(msg.data, "https://example.com/post") {
+			t.Errorf("message body has no post link")
+		}
+	}
+}
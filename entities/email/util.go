@@ -0,0 +1,95 @@
+package email
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var emptylines = regexp.MustCompile(`\n{3,}`)
+
+// htmlToText convert HTML to plain text with links in parentheses
+func htmlToText(html string) string {
+	replacer := strings.NewReplacer(
+		"<br>", "\n",
+		"<br/>", "\n",
+		"<br />", "\n",
+		"</p>", "</p>\n\n",
+		"</div>", "</div>\n",
+		"</li>", "</li>\n",
+	)
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(replacer.Replace(html)))
+	if err != nil {
+		return html
+	}
+	doc.Find("a").Each(func(i int, sel *goquery.Selection) {
+		href := sel.AttrOr("href", "")
+		if href != "" && href != sel.Text() {
+			sel.AppendHtml(" (" + href + ")")
+		}
+	})
+	text := emptylines.ReplaceAllString(doc.Text(), "\n\n")
+	return strings.TrimSpace(text)
+}
+
+// truncateLine return first line of text limited in length
+func truncateLine(text string, limit int) string {
+	line := strings.SplitN(text, "\n", 2)[0]
+	runes := []rune(line)
+	if len(runes) > limit {
+		return string(runes[:limit-1]) + "…"
+	}
+	return line
+}
+
+// writeText part in quoted-printable encoding
+func writeText(w *multipart.Writer, contentType, text string) error {
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType + "; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	qp := quotedprintable.NewWriter(part)
+	_, err = qp.Write([]byte(text))
+	if err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// writeAttachment part in base64 encoding, inline if it has content ID
+func writeAttachment(w *multipart.Writer, a attachment) error {
+	header := textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType(a.contentType, map[string]string{"name": a.name})},
+		"Content-Transfer-Encoding": {"base64"},
+	}
+	if a.contentID != "" {
+		header.Set("Content-ID", fmt.Sprintf("<%s>", a.contentID))
+		header.Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": a.name}))
+	} else {
+		header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.name}))
+	}
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	encoded := base64.StdEncoding.EncodeToString(a.data)
+	for len(encoded) > 76 {
+		_, err = part.Write([]byte(encoded[:76] + "\r\n"))
+		if err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err = part.Write([]byte(encoded + "\r\n"))
+	return err
+}
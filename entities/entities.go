@@ -2,12 +2,17 @@ package entities
 
 import (
 	// Entities
+	_ "github.com/n0madic/crossposter/entities/email"
+	_ "github.com/n0madic/crossposter/entities/imap"
 	_ "github.com/n0madic/crossposter/entities/instagram"
+	_ "github.com/n0madic/crossposter/entities/jsonapi"
 	_ "github.com/n0madic/crossposter/entities/pikabu"
 	_ "github.com/n0madic/crossposter/entities/reddit"
 	_ "github.com/n0madic/crossposter/entities/rss"
+	_ "github.com/n0madic/crossposter/entities/scrape"
 	_ "github.com/n0madic/crossposter/entities/telegram"
 	_ "github.com/n0madic/crossposter/entities/test"
 	_ "github.com/n0madic/crossposter/entities/twitter"
 	_ "github.com/n0madic/crossposter/entities/vk"
+	_ "github.com/n0madic/crossposter/entities/webhook"
 )
@@ -0,0 +1,263 @@
+package imap
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	imapapi "github.com/emersion/go-imap"
+	imapclient "github.com/emersion/go-imap/client"
+	"github.com/n0madic/crossposter"
+	log "github.com/sirupsen/logrus"
+)
+
+// IMAP entity
+type IMAP struct {
+	entity    *crossposter.Entity
+	addr      string
+	host      string
+	security  string
+	allowed   []string
+	moveTo    string
+	mediaDir  string
+	publicURL string
+	mediaPath string
+}
+
+type message struct {
+	uid  uint32
+	post crossposter.Post
+	from string
+}
+
+// Paths of attachments served by IMAP entities
+var mediaPaths = make(map[string]bool)
+
+func init() {
+	crossposter.AddEntity("imap", New)
+}
+
+// New return IMAP entity
+func New(entity crossposter.Entity) (crossposter.EntityInterface, error) {
+	box := &IMAP{
+		entity:    &entity,
+		host:      entity.Options["host"],
+		security:  strings.ToLower(entity.Options["security"]),
+		moveTo:    entity.Options["move"],
+		mediaDir:  entity.Options["media_dir"],
+		publicURL: strings.TrimSuffix(entity.Options["public_url"], "/"),
+	}
+	if box.host == "" {
+		return nil, fmt.Errorf("IMAP host not specified")
+	}
+	port := entity.Options["port"]
+	switch box.security {
+	case "", "tls":
+		box.security = "tls"
+		if port == "" {
+			port = "993"
+		}
+	case "starttls", "none":
+		if port == "" {
+			port = "143"
+		}
+	default:
+		return nil, fmt.Errorf("unsupported IMAP security: %s", box.security)
+	}
+	box.addr = net.JoinHostPort(box.host, port)
+
+	for _, addr := range strings.Split(entity.Options["allow"], ",") {
+		if addr = strings.ToLower(strings.TrimSpace(addr)); addr != "" {
+			box.allowed = append(box.allowed, addr)
+		}
+	}
+
+	if box.mediaDir == "" {
+		box.mediaDir = filepath.Join(os.TempDir(), "crossposter-imap")
+	}
+	if box.publicURL != "" {
+		name := entity.Options["name"]
+		if name == "" {
+			name = box.host
+		}
+		box.mediaPath = "/imap/" + url.PathEscape(name) + "/"
+		if mediaPaths[box.mediaPath] {
+			return nil, fmt.Errorf("path %s of IMAP attachments already used, set unique name option", box.mediaPath)
+		}
+		mediaPaths[box.mediaPath] = true
+
+		err := os.MkdirAll(box.mediaDir, 0755)
+		if err != nil {
+			return nil, err
+		}
+		http.HandleFunc(box.mediaPath, box.Handler)
+	} else {
+		log.WithFields(log.Fields{"host": box.host, "type": entity.Type}).Warn("Image attachments are skipped without public_url option")
+	}
+
+	return box, nil
+}
+
+// Get messages from IMAP folders
+func (box *IMAP) Get(lastUpdate time.Time) {
+	defer crossposter.WaitGroup.Done()
+
+	for {
+		imapLogger := log.WithFields(log.Fields{"host": box.host, "type": box.entity.Type})
+		client, err := box.connect()
+		if err != nil {
+			imapLogger.Error(err)
+		} else {
+			for _, folder := range box.entity.Sources {
+				folderLogger := imapLogger.WithField("folder", folder)
+				folderLogger.Println("Check updates")
+				err := box.processFolder(client, folder, folderLogger)
+				if err != nil {
+					folderLogger.Error(err)
+				}
+			}
+			client.Logout()
+		}
+		time.Sleep(time.Duration(box.entity.Wait) * time.Minute)
+	}
+}
+
+// Post not implemented
+func (box *IMAP) Post(post crossposter.Post) {}
+
+// Handler serve saved attachments
+func (box *IMAP) Handler(w http.ResponseWriter, r *http.Request) {
+	name := path.Base(r.URL.Path)
+	if name == "/" || name == "." || strings.HasPrefix(name, ".") {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFile(w, r, filepath.Join(box.mediaDir, name))
+}
+
+// connect and login to IMAP server
+func (box *IMAP) connect() (*imapclient.Client, error) {
+	tlsConfig := &tls.Config{ServerName: box.host}
+
+	var client *imapclient.Client
+	var err error
+	if box.security == "tls" {
+		client, err = imapclient.DialTLS(box.addr, tlsConfig)
+	} else {
+		client, err = imapclient.Dial(box.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if box.security == "starttls" {
+		err = client.StartTLS(tlsConfig)
+		if err != nil {
+			client.Logout()
+			return nil, err
+		}
+	}
+
+	err = client.Login(box.entity.Options["user"], box.entity.Options["password"])
+	if err != nil {
+		client.Logout()
+		return nil, fmt.Errorf("failed to login: %v", err)
+	}
+	return client, nil
+}
+
+// processFolder publish unseen messages from folder and mark them processed
+func (box *IMAP) processFolder(client *imapclient.Client, folder string, logger *log.Entry) error {
+	_, err := client.Select(folder, false)
+	if err != nil {
+		return err
+	}
+
+	criteria := imapapi.NewSearchCriteria()
+	criteria.WithoutFlags = []string{imapapi.SeenFlag}
+	uids, err := client.UidSearch(criteria)
+	if err != nil {
+		return err
+	}
+	if len(uids) == 0 {
+		return nil
+	}
+
+	seqset := new(imapapi.SeqSet)
+	seqset.AddNum(uids...)
+	section := &imapapi.BodySectionName{Peek: true}
+	items := []imapapi.FetchItem{section.FetchItem(), imapapi.FetchUid}
+
+	fetched := make(chan *imapapi.Message, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- client.UidFetch(seqset, items, fetched)
+	}()
+
+	var messages []message
+	// Broken messages are flagged as seen to not fetch them again
+	failed := new(imapapi.SeqSet)
+	for msg := range fetched {
+		body := msg.GetBody(section)
+		if body == nil {
+			continue
+		}
+		m, err := mail.ReadMessage(body)
+		if err != nil {
+			logger.Warnf("Can't parse message %d: %s", msg.Uid, err)
+			failed.AddNum(msg.Uid)
+			continue
+		}
+		parsed, err := box.parseMessage(msg.Uid, m)
+		if err != nil {
+			logger.Warnf("Can't parse message %d: %s", msg.Uid, err)
+			failed.AddNum(msg.Uid)
+			continue
+		}
+		messages = append(messages, parsed)
+	}
+	if err := <-done; err != nil {
+		return err
+	}
+
+	flags := []interface{}{imapapi.SeenFlag}
+	if !failed.Empty() {
+		err := client.UidStore(failed, imapapi.FormatFlagsOp(imapapi.AddFlags, true), flags, nil)
+		if err != nil {
+			logger.Error(err)
+		}
+	}
+
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].post.Date.Before(messages[j].post.Date)
+	})
+
+	processed := new(imapapi.SeqSet)
+	for _, msg := range messages {
+		processed.AddNum(msg.uid)
+		if len(box.allowed) > 0 && !allowedSender(msg.from, box.allowed) {
+			logger.Warnf("Skip message from not allowed sender %s", msg.from)
+			continue
+		}
+		for _, topic := range box.entity.Topics {
+			crossposter.Events.Publish(topic, msg.post)
+		}
+	}
+
+	if processed.Empty() {
+		return nil
+	}
+	if box.moveTo != "" {
+		return client.UidMove(processed, box.moveTo)
+	}
+	return client.UidStore(processed, imapapi.FormatFlagsOp(imapapi.AddFlags, true), flags, nil)
+}
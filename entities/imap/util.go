@@ -0,0 +1,200 @@
+package imap
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/djimenez/iconv-go"
+	"github.com/n0madic/crossposter"
+	log "github.com/sirupsen/logrus"
+)
+
+var reCidImages = regexp.MustCompile(`(?i)<img[^>]+src=["']?cid:[^>]*>`)
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: charsetReader}
+
+// parseMessage convert mail to post
+func (box *IMAP) parseMessage(uid uint32, m *mail.Message) (message, error) {
+	msg := message{uid: uid}
+
+	subject, err := wordDecoder.DecodeHeader(m.Header.Get("Subject"))
+	if err != nil {
+		subject = m.Header.Get("Subject")
+	}
+
+	author := ""
+	if from, err := (&mail.AddressParser{WordDecoder: wordDecoder}).Parse(m.Header.Get("From")); err == nil {
+		msg.from = strings.ToLower(from.Address)
+		author = from.Name
+		if author == "" {
+			author = from.Address
+		}
+	}
+
+	date, err := m.Header.Date()
+	if err != nil {
+		date = time.Now()
+	}
+
+	var htmlBody, textBody string
+	var images []string
+	err = box.walkPart(m.Header, m.Body, func(contentType string, params map[string]string, filename string, data []byte) {
+		switch {
+		case strings.HasPrefix(contentType, "image/"):
+			if url, err := box.saveImage(filename, data); err == nil {
+				images = append(images, url)
+			} else {
+				log.WithFields(log.Fields{"host": box.host, "file": filename, "type": box.entity.Type}).Warnf("Skip image attachment: %s", err)
+			}
+		case filename != "":
+		case contentType == "text/html" && htmlBody == "":
+			htmlBody = toUTF8(data, params["charset"])
+		case contentType == "text/plain" && textBody == "":
+			textBody = toUTF8(data, params["charset"])
+		}
+	})
+	if err != nil {
+		return msg, err
+	}
+
+	text := strings.TrimSpace(textBody)
+	if htmlBody != "" {
+		text = strings.TrimSpace(reCidImages.ReplaceAllString(extractBody(htmlBody), ""))
+	}
+
+	msg.post = crossposter.Post{
+		Date:        date,
+		Author:      author,
+		Title:       strings.TrimSpace(subject),
+		Text:        text,
+		Attachments: images,
+	}
+	return msg, nil
+}
+
+// partHeader is common interface of message and part headers
+type partHeader interface {
+	Get(key string) string
+}
+
+// walkPart recursively walk through MIME parts and call fn for every leaf part
+func (box *IMAP) walkPart(header partHeader, body io.Reader, fn func(string, map[string]string, string, []byte)) error {
+	contentType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		contentType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(contentType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			err = box.walkPart(part.Header, part, fn)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	switch strings.ToLower(header.Get("Content-Transfer-Encoding")) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	filename := ""
+	if _, dparams, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil {
+		filename = dparams["filename"]
+	}
+	if filename == "" {
+		filename = params["name"]
+	}
+	if decoded, err := wordDecoder.DecodeHeader(filename); err == nil {
+		filename = decoded
+	}
+
+	fn(contentType, params, filename, data)
+	return nil
+}
+
+// saveImage to media directory and return its public URL
+func (box *IMAP) saveImage(filename string, data []byte) (string, error) {
+	if box.publicURL == "" {
+		return "", fmt.Errorf("public URL for attachments not configured")
+	}
+	sum := sha1.Sum(data)
+	name := hex.EncodeToString(sum[:]) + strings.ToLower(filepath.Ext(filename))
+	err := ioutil.WriteFile(filepath.Join(box.mediaDir, name), data, 0644)
+	if err != nil {
+		return "", err
+	}
+	return box.publicURL + box.mediaPath + name, nil
+}
+
+// allowedSender check sender address or domain in allowlist
+func allowedSender(from string, allowed []string) bool {
+	for _, addr := range allowed {
+		if from == addr || (strings.HasPrefix(addr, "@") && strings.HasSuffix(from, addr)) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractBody return content of body tag if exists
+func extractBody(content string) string {
+	lower := strings.ToLower(content)
+	start := strings.Index(lower, "<body")
+	if start == -1 {
+		return content
+	}
+	closing := strings.Index(lower[start:], ">")
+	if closing == -1 {
+		return content
+	}
+	start += closing + 1
+	end := strings.LastIndex(lower, "</body>")
+	if end < start {
+		end = len(content)
+	}
+	return content[start:end]
+}
+
+// toUTF8 convert text from charset
+func toUTF8(data []byte, charset string) string {
+	charset = strings.ToLower(charset)
+	if charset == "" || charset == "utf-8" || charset == "us-ascii" {
+		return string(data)
+	}
+	converted, err := iconv.ConvertString(string(data), charset, "utf-8")
+	if err != nil {
+		return string(data)
+	}
+	return converted
+}
+
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	return iconv.NewReader(input, charset, "utf-8")
+}
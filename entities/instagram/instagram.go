@@ -36,6 +36,7 @@ func New(entity crossposter.Entity) (crossposter.EntityInterface, error) {
 // Get user's feed from Instagram
 func (inst *Instagram) Get(lastUpdate time.Time) {
 	defer crossposter.WaitGroup.Done()
+	checkpoints := crossposter.NewCheckpoints(lastUpdate)
 
 	for {
 		for _, name := range inst.entity.Sources {
@@ -56,8 +57,8 @@ func (inst *Instagram) Get(lastUpdate time.Time) {
 
 				for _, item := range media.Items {
 					itime := time.Unix(int64(item.TakenAt), 0)
-					if itime.After(lastUpdate) {
-						lastUpdate = itime
+					if checkpoints.IsNew(name, itime) {
+						checkpoints.Update(name, itime)
 						mediaURLs := []string{}
 						if item.Images.GetBest() != "" {
 							mediaURLs = append(mediaURLs, item.Images.GetBest())
@@ -0,0 +1,246 @@
+package jsonapi
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/n0madic/crossposter"
+	"github.com/n0madic/crossposter/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultPages       = 1
+	headerOptionPrefix = "header_"
+)
+
+// Options with JSONPath of item fields
+var pathOptions = []string{"id", "link", "title", "text", "author", "date", "attachments", "cursor"}
+
+// JSONAPI entity
+type JSONAPI struct {
+	entity *crossposter.Entity
+}
+
+func init() {
+	crossposter.AddEntity("jsonapi", New)
+}
+
+// New return JSON API entity
+func New(entity crossposter.Entity) (crossposter.EntityInterface, error) {
+	for _, source := range entity.Sources {
+		if _, err := parsePath(entity.Option("items", source)); err != nil {
+			return nil, fmt.Errorf("invalid items path for %s: %v", source, err)
+		}
+		for _, name := range pathOptions {
+			if _, err := parsePath(entity.Option(name, source)); err != nil {
+				return nil, fmt.Errorf("invalid %s path for %s: %v", name, source, err)
+			}
+		}
+	}
+	return &JSONAPI{entity: &entity}, nil
+}
+
+// Get items from JSON API
+func (api *JSONAPI) Get(lastUpdate time.Time) {
+	defer crossposter.WaitGroup.Done()
+	published, err := crossposter.NewPublished(10000, lastUpdate)
+	if err != nil {
+		log.WithFields(log.Fields{"type": api.entity.Type}).Error(err)
+		return
+	}
+
+	for {
+		for _, source := range api.entity.Sources {
+			apiLogger := log.WithFields(log.Fields{"source": source, "type": api.entity.Type})
+			apiLogger.Println("Check updates")
+
+			posts, err := api.fetch(source)
+			if err != nil {
+				apiLogger.Error(err)
+				if len(posts) == 0 {
+					continue
+				}
+			}
+
+			sort.SliceStable(posts, func(i, j int) bool {
+				return posts[i].Date.Before(posts[j].Date)
+			})
+
+			// Without dates items found on first check are considered old
+			for _, p := range posts {
+				if published.IsNew(source, p.id, p.Date) {
+					if p.Date.IsZero() {
+						p.Date = time.Now()
+					}
+					for _, topic := range api.entity.Topics {
+						crossposter.Events.Publish(topic, p.Post)
+					}
+				}
+			}
+			published.Initialize(source)
+		}
+		time.Sleep(time.Duration(api.entity.Wait) * time.Minute)
+	}
+}
+
+// Post not implemented
+func (api *JSONAPI) Post(post crossposter.Post) {}
+
+// Handler not implemented
+func (api *JSONAPI) Handler(w http.ResponseWriter, r *http.Request) {}
+
+type post struct {
+	crossposter.Post
+	id string
+}
+
+// fetch posts from source pages
+func (api *JSONAPI) fetch(source string) ([]post, error) {
+	option := func(name string) string {
+		return api.entity.Option(name, source)
+	}
+
+	pages := defaultPages
+	if option("pages") != "" {
+		n, err := strconv.Atoi(option("pages"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pages: %v", err)
+		}
+		pages = n
+	}
+
+	headers := make(map[string]string)
+	for key, value := range api.entity.Options {
+		if strings.HasPrefix(key, headerOptionPrefix) {
+			headers[strings.TrimPrefix(key, headerOptionPrefix)] = value
+		}
+	}
+	for key, value := range api.entity.Overrides[source] {
+		if strings.HasPrefix(key, headerOptionPrefix) {
+			headers[strings.TrimPrefix(key, headerOptionPrefix)] = value
+		}
+	}
+	if token := option("token"); token != "" {
+		headers["Authorization"] = "Bearer " + token
+	} else if user := option("user"); user != "" {
+		headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+option("password")))
+	}
+
+	pageURL := source
+	if option("url") != "" {
+		pageURL = option("url")
+	}
+
+	var posts []post
+	for page := 0; page < pages && pageURL != ""; page++ {
+		var doc interface{}
+		err := utils.GetJSONWithHeaders(pageURL, headers, &doc)
+		if err != nil {
+			return posts, err
+		}
+
+		items, err := query(doc, option("items"))
+		if err != nil {
+			return posts, err
+		}
+		if len(items) == 1 {
+			if list, ok := items[0].([]interface{}); ok {
+				items = list
+			}
+		}
+		for _, item := range items {
+			p, err := parseItem(item, option)
+			if err != nil {
+				log.WithFields(log.Fields{"source": source, "type": api.entity.Type}).Warnf("Skip item: %s", err)
+				continue
+			}
+			posts = append(posts, p)
+		}
+
+		cursor := queryString(doc, option("cursor"))
+		if cursor == "" || len(items) == 0 {
+			break
+		}
+		pageURL, err = nextPage(pageURL, cursor, option("cursor_param"))
+		if err != nil {
+			return posts, err
+		}
+	}
+	return posts, nil
+}
+
+// parseItem map item fields to post
+func parseItem(item interface{}, option func(string) string) (post, error) {
+	p := post{
+		Post: crossposter.Post{
+			URL:         queryString(item, option("link")),
+			Author:      queryString(item, option("author")),
+			Title:       queryString(item, option("title")),
+			Text:        queryString(item, option("text")),
+			Attachments: queryStrings(item, option("attachments")),
+		},
+		id: queryString(item, option("id")),
+	}
+	if prefix := option("link_prefix"); prefix != "" && p.URL != "" {
+		p.URL = prefix + p.URL
+	}
+	if p.id == "" {
+		p.id = p.URL
+	}
+	if p.id == "" {
+		return p, fmt.Errorf("item has neither id nor link")
+	}
+
+	if value := queryString(item, option("date")); value != "" {
+		date, err := parseDate(value, option("date_layout"))
+		if err != nil {
+			return p, fmt.Errorf("can't parse date of %s: %v", p.id, err)
+		}
+		p.Date = date
+	}
+	return p, nil
+}
+
+// parseDate with layout or as epoch in seconds or milliseconds
+func parseDate(value, layout string) (time.Time, error) {
+	switch layout {
+	case "", "unix", "unix_ms":
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			if layout == "" {
+				return time.Parse(time.RFC3339, value)
+			}
+			return time.Time{}, err
+		}
+		if layout == "unix_ms" {
+			return time.Unix(0, int64(number)*int64(time.Millisecond)), nil
+		}
+		return time.Unix(int64(number), 0), nil
+	}
+	return time.Parse(layout, value)
+}
+
+// nextPage build URL of next page with cursor
+func nextPage(pageURL, cursor, param string) (string, error) {
+	if param == "" {
+		if utils.IsRequestURL(cursor) {
+			return cursor, nil
+		}
+		return "", fmt.Errorf("cursor_param not specified for cursor %q", cursor)
+	}
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set(param, cursor)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
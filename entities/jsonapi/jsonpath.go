@@ -0,0 +1,156 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pathStep is one step of parsed JSONPath
+type pathStep struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// parsePath parse simple JSONPath like $.data.items[*].title or $['key'][0]
+func parsePath(path string) ([]pathStep, error) {
+	path = strings.TrimSpace(path)
+	path = strings.TrimPrefix(path, "$")
+	var steps []pathStep
+	for len(path) > 0 {
+		switch path[0] {
+		case '.':
+			path = path[1:]
+			end := strings.IndexAny(path, ".[")
+			if end == -1 {
+				end = len(path)
+			}
+			key := path[:end]
+			path = path[end:]
+			if key == "" {
+				return nil, fmt.Errorf("empty key in path")
+			}
+			if key == "*" {
+				steps = append(steps, pathStep{wildcard: true})
+			} else {
+				steps = append(steps, pathStep{key: key})
+			}
+		case '[':
+			end := strings.Index(path, "]")
+			if end == -1 {
+				return nil, fmt.Errorf("unclosed bracket in path")
+			}
+			inner := strings.TrimSpace(path[1:end])
+			path = path[end+1:]
+			switch {
+			case inner == "*":
+				steps = append(steps, pathStep{wildcard: true})
+			case strings.HasPrefix(inner, "'") || strings.HasPrefix(inner, `"`):
+				steps = append(steps, pathStep{key: strings.Trim(inner, `'"`)})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index %q in path", inner)
+				}
+				steps = append(steps, pathStep{index: index, isIndex: true})
+			}
+		default:
+			// Path without leading dot
+			path = "." + path
+		}
+	}
+	return steps, nil
+}
+
+// query values from document by JSONPath
+func query(doc interface{}, path string) ([]interface{}, error) {
+	steps, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	nodes := []interface{}{doc}
+	for _, step := range steps {
+		var next []interface{}
+		for _, node := range nodes {
+			switch value := node.(type) {
+			case map[string]interface{}:
+				if step.wildcard {
+					for _, v := range value {
+						next = append(next, v)
+					}
+				} else if v, ok := value[step.key]; ok && !step.isIndex {
+					next = append(next, v)
+				}
+			case []interface{}:
+				switch {
+				case step.wildcard:
+					next = append(next, value...)
+				case step.isIndex:
+					index := step.index
+					if index < 0 {
+						index += len(value)
+					}
+					if index >= 0 && index < len(value) {
+						next = append(next, value[index])
+					}
+				}
+			}
+		}
+		nodes = next
+	}
+	return nodes, nil
+}
+
+// queryString return first value by JSONPath as string
+func queryString(doc interface{}, path string) string {
+	if path == "" {
+		return ""
+	}
+	values, err := query(doc, path)
+	if err != nil || len(values) == 0 {
+		return ""
+	}
+	return toString(values[0])
+}
+
+// queryStrings return all values by JSONPath as strings, arrays are flattened
+func queryStrings(doc interface{}, path string) []string {
+	if path == "" {
+		return nil
+	}
+	values, err := query(doc, path)
+	if err != nil {
+		return nil
+	}
+	var result []string
+	for _, value := range values {
+		if list, ok := value.([]interface{}); ok {
+			for _, v := range list {
+				if s := toString(v); s != "" {
+					result = append(result, s)
+				}
+			}
+		} else if s := toString(value); s != "" {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	b, _ := json.Marshal(value)
+	return string(b)
+}
@@ -2,7 +2,9 @@ package pikabu
 
 import (
 	"net/http"
+	"net/http/cookiejar"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -18,6 +20,10 @@ import (
 type Pikabu struct {
 	entity    *crossposter.Entity
 	published *lru.Cache
+	client    *http.Client
+	loggedIn  bool
+	loginURL  string
+	submitURL string
 }
 
 var mutex sync.Mutex
@@ -29,12 +35,30 @@ func init() {
 // New run Pikabu entity
 func New(entity crossposter.Entity) (crossposter.EntityInterface, error) {
 	cache, _ := lru.New(10000)
-	return &Pikabu{entity: &entity, published: cache}, nil
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	pikabu := &Pikabu{
+		entity:    &entity,
+		published: cache,
+		client:    &http.Client{Jar: jar, Timeout: 30 * time.Second},
+		loginURL:  entity.Options["login_url"],
+		submitURL: entity.Options["submit_url"],
+	}
+	if pikabu.loginURL == "" {
+		pikabu.loginURL = defaultLoginURL
+	}
+	if pikabu.submitURL == "" {
+		pikabu.submitURL = defaultSubmitURL
+	}
+	return pikabu, nil
 }
 
 // Get items from Pikabu
 func (pikabu *Pikabu) Get(lastUpdate time.Time) {
 	defer crossposter.WaitGroup.Done()
+	checkpoints := crossposter.NewCheckpoints(lastUpdate)
 
 	for {
 		for _, location := range pikabu.entity.Sources {
@@ -49,43 +73,71 @@ func (pikabu *Pikabu) Get(lastUpdate time.Time) {
 			} else {
 				posts := []crossposter.Post{}
 
+				minRating, _ := strconv.Atoi(pikabu.entity.Option("min_rating", location))
+
 				doc.Find(".story__main").Each(func(i int, sel *goquery.Selection) {
 					sponsor := false
 					sel.Find(".story__sponsor").Each(func(i int, c *goquery.Selection) {
 						sponsor = true
 					})
 					timestamp, _ := time.Parse(time.RFC3339, sel.Find(".story__datetime").First().AttrOr("datetime", ""))
-					if !sponsor && !timestamp.IsZero() {
-						var mediaURLs []string
-						story := sel.Find(".story__content-inner").Each(func(i int, sel *goquery.Selection) {
-							sel.Find("div.player").Each(func(i int, sel *goquery.Selection) {
-								mediaURLs = append(mediaURLs, sel.AttrOr("data-source", ""))
-							})
+					if sponsor || timestamp.IsZero() {
+						return
+					}
+
+					block := sel.ParentsFiltered(".story").First()
+					if block.Length() == 0 {
+						block = sel
+					}
+					rating, ratingKnown := storyRating(block)
+					if minRating != 0 && (!ratingKnown || rating < minRating) {
+						return
+					}
+
+					var mediaURLs []string
+					story := sel.Find(".story__content-inner").Each(func(i int, sel *goquery.Selection) {
+						sel.Find("div.player").Each(func(i int, sel *goquery.Selection) {
+							mediaURLs = append(mediaURLs, sel.AttrOr("data-source", ""))
 						})
-						html, err := story.Html()
-						if err != nil {
-							html = story.Text()
-						}
-						post := crossposter.Post{
-							Date:        timestamp,
-							URL:         sel.Find(".story__title > a").First().AttrOr("href", ""),
-							Author:      doc.Find(".user__nick").First().Text(),
-							Title:       strings.TrimSpace(sel.Find(".story__title").First().Text()),
-							Text:        strings.TrimSpace(html),
-							Attachments: mediaURLs,
-							More:        false,
-						}
-						posts = append(posts, post)
+					})
+					html, err := story.Html()
+					if err != nil {
+						html = story.Text()
 					}
+
+					metadata := map[string]string{
+						"story_id": block.AttrOr("data-story-id", ""),
+						"comments": storyComments(block),
+					}
+					if ratingKnown {
+						metadata["rating"] = strconv.Itoa(rating)
+					}
+
+					post := crossposter.Post{
+						Date:        timestamp,
+						URL:         sel.Find(".story__title > a").First().AttrOr("href", ""),
+						Author:      storyAuthor(block),
+						Title:       strings.TrimSpace(sel.Find(".story__title").First().Text()),
+						Text:        strings.TrimSpace(html),
+						Attachments: mediaURLs,
+						More:        false,
+						Metadata:    metadata,
+					}
+					posts = append(posts, post)
 				})
 
 				sort.Slice(posts, func(i, j int) bool {
 					return posts[i].Date.Before(posts[j].Date)
 				})
 
+				// Stories reaching rating threshold are published even if newer stories were published before
+				checkpoint := checkpoints.Get(location)
+				if minRating != 0 {
+					checkpoint = lastUpdate
+				}
 				for _, post := range posts {
-					if post.Date.After(lastUpdate) && !pikabu.published.Contains(post.URL) {
-						lastUpdate = post.Date
+					if post.Date.After(checkpoint) && !pikabu.published.Contains(post.URL) {
+						checkpoints.Update(location, post.Date)
 						pikabu.published.Add(post.URL, nil)
 						for _, topic := range pikabu.entity.Topics {
 							crossposter.Events.Publish(topic, post)
@@ -99,8 +151,31 @@ func (pikabu *Pikabu) Get(lastUpdate time.Time) {
 	}
 }
 
-// Post not implemented
-func (pikabu *Pikabu) Post(post crossposter.Post) {}
+// Post story to Pikabu communities
+func (pikabu *Pikabu) Post(post crossposter.Post) {
+	if pikabu.entity.Options["user"] == "" {
+		log.WithFields(log.Fields{"type": pikabu.entity.Type}).Error("Pikabu credentials not configured")
+		return
+	}
+
+	err := post.ExtractImages()
+	if err != nil {
+		log.WithFields(log.Fields{"type": pikabu.entity.Type}).Warnf("Can't extract image: %s", err)
+	}
+
+	for _, community := range pikabu.entity.Destinations {
+		pikabuLogger := log.WithFields(log.Fields{"community": community, "type": pikabu.entity.Type})
+
+		mutex.Lock()
+		storyURL, err := pikabu.submitStory(community, post)
+		mutex.Unlock()
+		if err != nil {
+			pikabuLogger.Error(err)
+		} else {
+			pikabuLogger.Printf("Posted %s", storyURL)
+		}
+	}
+}
 
 // Handler not implemented
 func (pikabu *Pikabu) Handler(w http.ResponseWriter, r *http.Request) {}
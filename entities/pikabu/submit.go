@@ -0,0 +1,154 @@
+package pikabu
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/n0madic/crossposter"
+)
+
+const (
+	baseURL          = "https://pikabu.ru"
+	defaultLoginURL  = baseURL + "/ajax/auth.php"
+	defaultSubmitURL = baseURL + "/ajax/story_add.php"
+	maxTags          = 7
+)
+
+// storyBlock is text or image block of story
+type storyBlock struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+type ajaxResponse struct {
+	Result  bool   `json:"result"`
+	Message string `json:"message"`
+	Data    struct {
+		StoryURL string `json:"story_url"`
+	} `json:"data"`
+}
+
+// login to Pikabu with session cookies
+func (pikabu *Pikabu) login() error {
+	if pikabu.loggedIn {
+		return nil
+	}
+
+	// Get session cookies before login
+	res, err := pikabu.client.Get(baseURL)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	form := url.Values{}
+	form.Set("mode", "login")
+	form.Set("username", pikabu.entity.Options["user"])
+	form.Set("password", pikabu.entity.Options["password"])
+	form.Set("remember", "1")
+
+	response, err := pikabu.ajax(pikabu.loginURL, form)
+	if err != nil {
+		return fmt.Errorf("failed to login: %v", err)
+	}
+	if !response.Result {
+		return fmt.Errorf("failed to login: %s", response.Message)
+	}
+	pikabu.loggedIn = true
+	return nil
+}
+
+// submitStory create story in community with text, images and tags
+func (pikabu *Pikabu) submitStory(community string, post crossposter.Post) (string, error) {
+	err := pikabu.login()
+	if err != nil {
+		return "", err
+	}
+
+	blocks := storyBlocks(post)
+	content, err := json.Marshal(blocks)
+	if err != nil {
+		return "", err
+	}
+
+	title := post.Title
+	if title == "" {
+		title = firstLine(post.Text)
+	}
+
+	var tags []string
+	for _, tag := range strings.Split(pikabu.entity.Option("tags", community), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" && len(tags) < maxTags {
+			tags = append(tags, tag)
+		}
+	}
+
+	form := url.Values{}
+	form.Set("title", title)
+	form.Set("blocks", string(content))
+	form.Set("tags", strings.Join(tags, ","))
+	if community != "" && community != "profile" {
+		form.Set("community", community)
+	}
+	if strings.EqualFold(pikabu.entity.Option("mine", community), "true") {
+		form.Set("is_authors", "1")
+	}
+
+	response, err := pikabu.ajax(pikabu.submitURL, form)
+	if err != nil {
+		pikabu.loggedIn = false
+		return "", err
+	}
+	if !response.Result {
+		return "", fmt.Errorf("can't create story: %s", response.Message)
+	}
+	return response.Data.StoryURL, nil
+}
+
+// ajax POST form to Pikabu endpoint and decode response
+func (pikabu *Pikabu) ajax(endpoint string, form url.Values) (ajaxResponse, error) {
+	var response ajaxResponse
+
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return response, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=UTF-8")
+	req.Header.Set("X-Requested-With", "XMLHttpRequest")
+	req.Header.Set("Referer", baseURL+"/")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; Crossposter/1.0)")
+
+	res, err := pikabu.client.Do(req)
+	if err != nil {
+		return response, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return response, fmt.Errorf("bad status: %s", res.Status)
+	}
+	err = json.NewDecoder(res.Body).Decode(&response)
+	return response, err
+}
+
+// storyBlocks build story blocks from post text and attachments
+func storyBlocks(post crossposter.Post) []storyBlock {
+	var blocks []storyBlock
+	if text := sanitize(post.Text); text != "" {
+		blocks = append(blocks, storyBlock{Type: "t", Data: text})
+	}
+	for _, attach := range post.Attachments {
+		blocks = append(blocks, storyBlock{Type: "i", Data: map[string]string{"url": attach}})
+	}
+	if post.URL != "" {
+		blocks = append(blocks, storyBlock{
+			Type: "t",
+			Data: fmt.Sprintf(`<p><a href="%s">%s</a></p>`, html.EscapeString(post.URL), html.EscapeString(post.URL)),
+		})
+	}
+	return blocks
+}
@@ -0,0 +1,54 @@
+package pikabu
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/microcosm-cc/bluemonday"
+)
+
+var reNotDigits = regexp.MustCompile(`[^\d-]`)
+
+// storyRating return rating of story if it is not hidden
+func storyRating(block *goquery.Selection) (int, bool) {
+	value, ok := block.Attr("data-rating")
+	if !ok {
+		value = block.Find(".story__rating-count").First().Text()
+	}
+	rating, err := strconv.Atoi(reNotDigits.ReplaceAllString(value, ""))
+	return rating, err == nil
+}
+
+// storyComments return count of story comments
+func storyComments(block *goquery.Selection) string {
+	value := block.Find(".story__comments-link-count").First().Text()
+	return reNotDigits.ReplaceAllString(strings.TrimSpace(value), "")
+}
+
+// sanitize HTML to tags allowed in story text blocks
+func sanitize(html string) string {
+	p := bluemonday.NewPolicy()
+	p.AllowStandardURLs()
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowElements("p", "br", "b", "strong", "i", "em", "s", "del", "blockquote", "ul", "ol", "li", "h2", "h3")
+	return strings.TrimSpace(p.Sanitize(html))
+}
+
+// firstLine of HTML text
+func firstLine(content string) string {
+	// Strict policy escapes text, so entities are decoded back
+	text := html.UnescapeString(bluemonday.StrictPolicy().Sanitize(strings.ReplaceAll(content, "<br>", "\n")))
+	return strings.TrimSpace(strings.SplitN(strings.TrimSpace(text), "\n", 2)[0])
+}
+
+// storyAuthor return nick of story author with fallback to any nick in block
+func storyAuthor(block *goquery.Selection) string {
+	nick := block.Find(".story__user .user__nick").First()
+	if nick.Length() == 0 {
+		nick = block.Find(".user__nick").First()
+	}
+	return strings.TrimSpace(nick.Text())
+}
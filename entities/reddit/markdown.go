@@ -0,0 +1,150 @@
+package reddit
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+var (
+	markdownEscaper = strings.NewReplacer(
+		`\`, `\\`,
+		"*", `\*`,
+		"_", `\_`,
+		"`", "\\`",
+		"[", `\[`,
+		"]", `\]`,
+		"^", `\^`,
+		"~", `\~`,
+		">", `\>`,
+	)
+	manyNewlines = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToMarkdown convert HTML text to Reddit flavored markdown
+func htmlToMarkdown(text string) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(text))
+	if err != nil {
+		return text
+	}
+	var sb strings.Builder
+	for _, node := range doc.Find("body").Nodes {
+		writeMarkdown(&sb, node, "")
+	}
+	result := manyNewlines.ReplaceAllString(sb.String(), "\n\n")
+	return strings.TrimSpace(result)
+}
+
+func writeChildren(sb *strings.Builder, node *html.Node, prefix string) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		writeMarkdown(sb, child, prefix)
+	}
+}
+
+func writeMarkdown(sb *strings.Builder, node *html.Node, prefix string) {
+	switch node.Type {
+	case html.TextNode:
+		// Single newline is not a line break in markdown
+		text := markdownEscaper.Replace(node.Data)
+		sb.WriteString(strings.ReplaceAll(text, "\n", "  \n"+prefix))
+		return
+	case html.ElementNode:
+	default:
+		writeChildren(sb, node, prefix)
+		return
+	}
+
+	switch node.Data {
+	case "br":
+		sb.WriteString("  \n" + prefix)
+	case "p", "div":
+		writeChildren(sb, node, prefix)
+		sb.WriteString("\n\n" + prefix)
+	case "b", "strong":
+		wrap(sb, node, prefix, "**")
+	case "i", "em":
+		wrap(sb, node, prefix, "*")
+	case "s", "strike", "del":
+		wrap(sb, node, prefix, "~~")
+	case "code":
+		sb.WriteString("`" + nodeText(node) + "`")
+	case "pre":
+		sb.WriteString("\n\n")
+		for _, line := range strings.Split(strings.TrimRight(nodeText(node), "\n"), "\n") {
+			sb.WriteString(prefix + "    " + line + "\n")
+		}
+		sb.WriteString("\n" + prefix)
+	case "a":
+		href := attr(node, "href")
+		if href == "" {
+			writeChildren(sb, node, prefix)
+			break
+		}
+		sb.WriteString("[")
+		writeChildren(sb, node, prefix)
+		sb.WriteString("](" + strings.ReplaceAll(href, ")", "%29") + ")")
+	case "img":
+		if src := attr(node, "src"); src != "" {
+			sb.WriteString("[" + markdownEscaper.Replace(attr(node, "alt")) + "](" + src + ")")
+		}
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		sb.WriteString("\n\n" + prefix + strings.Repeat("#", int(node.Data[1]-'0')) + " ")
+		writeChildren(sb, node, prefix)
+		sb.WriteString("\n\n" + prefix)
+	case "blockquote":
+		sb.WriteString("\n\n" + prefix + "> ")
+		writeChildren(sb, node, prefix+"> ")
+		sb.WriteString("\n\n" + prefix)
+	case "ul", "ol":
+		sb.WriteString("\n\n")
+		index := 1
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode || child.Data != "li" {
+				continue
+			}
+			marker := "* "
+			if node.Data == "ol" {
+				marker = strconv.Itoa(index) + ". "
+				index++
+			}
+			sb.WriteString(prefix + marker)
+			writeChildren(sb, child, prefix+"    ")
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n" + prefix)
+	case "script", "style":
+	default:
+		writeChildren(sb, node, prefix)
+	}
+}
+
+func wrap(sb *strings.Builder, node *html.Node, prefix, marker string) {
+	sb.WriteString(marker)
+	writeChildren(sb, node, prefix)
+	sb.WriteString(marker)
+}
+
+func nodeText(node *html.Node) string {
+	return goquery.NewDocumentFromNode(node).Text()
+}
+
+func attr(node *html.Node, name string) string {
+	for _, a := range node.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// htmlToText return plain text of HTML
+func htmlToText(text string) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(text))
+	if err != nil {
+		return text
+	}
+	return strings.TrimSpace(doc.Text())
+}
@@ -0,0 +1,149 @@
+package reddit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	tokenURL         = "https://www.reddit.com/api/v1/access_token"
+	oauthURL         = "https://oauth.reddit.com"
+	defaultUserAgent = "Crossposter/1.0"
+)
+
+// oauthClient of Reddit API with script app credentials
+type oauthClient struct {
+	clientID     string
+	clientSecret string
+	username     string
+	password     string
+	userAgent    string
+	token        string
+	expires      time.Time
+	remaining    float64
+	reset        time.Time
+	client       *http.Client
+	mutex        sync.Mutex
+}
+
+// authorize get new access token if current is expired
+func (c *oauthClient) authorize() error {
+	if c.token != "" && time.Now().Before(c.expires) {
+		return nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "password")
+	form.Set("username", c.username)
+	form.Set("password", c.password)
+	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.clientID, c.clientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", c.userAgent)
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+		Error       string `json:"error"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&token)
+	if err != nil {
+		return fmt.Errorf("can't get access token: %s", res.Status)
+	}
+	if token.AccessToken == "" {
+		return fmt.Errorf("can't get access token: %s %s", res.Status, token.Error)
+	}
+	c.token = token.AccessToken
+	c.expires = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return nil
+}
+
+// request Reddit API with form or JSON body and decode response to target
+func (c *oauthClient) request(method, path string, body interface{}, target interface{}) error {
+	c.mutex.Lock()
+	// Wait for reset of rate limit without holding the lock
+	if c.remaining < 1 && time.Now().Before(c.reset) {
+		wait := time.Until(c.reset)
+		c.mutex.Unlock()
+		time.Sleep(wait)
+		c.mutex.Lock()
+	}
+	defer c.mutex.Unlock()
+
+	err := c.authorize()
+	if err != nil {
+		return err
+	}
+
+	var reader io.Reader
+	contentType := ""
+	switch b := body.(type) {
+	case nil:
+	case url.Values:
+		reader = strings.NewReader(b.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+
+	req, err := http.NewRequest(method, oauthURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "bearer "+c.token)
+	req.Header.Set("User-Agent", c.userAgent)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if remaining, err := strconv.ParseFloat(res.Header.Get("X-Ratelimit-Remaining"), 64); err == nil {
+		c.remaining = remaining
+		if reset, err := strconv.Atoi(res.Header.Get("X-Ratelimit-Reset")); err == nil {
+			c.reset = time.Now().Add(time.Duration(reset) * time.Second)
+		}
+	} else {
+		c.remaining = 1
+	}
+
+	if res.StatusCode == http.StatusUnauthorized {
+		c.token = ""
+	}
+	if res.StatusCode != http.StatusOK {
+		content, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("bad status: %s %s", res.Status, strings.TrimSpace(string(content)))
+	}
+
+	if target == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(target)
+}
@@ -4,11 +4,12 @@ import (
 	"fmt"
 	"html"
 	"net/http"
+	"net/url"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
-	lru "github.com/hashicorp/golang-lru"
 	"github.com/n0madic/crossposter"
 	"github.com/n0madic/crossposter/utils"
 	log "github.com/sirupsen/logrus"
@@ -16,8 +17,15 @@ import (
 
 // Reddit entity
 type Reddit struct {
-	entity    *crossposter.Entity
-	published *lru.Cache
+	entity *crossposter.Entity
+	client *oauthClient
+}
+
+// submission prepared from post
+type submission struct {
+	kind        string
+	url         string
+	attachments []string
 }
 
 type (
@@ -25,8 +33,12 @@ type (
 		Author        string        `json:"author"`
 		BodyHTML      string        `json:"body_html"`
 		CreatedUTC    jsonTimestamp `json:"created_utc"`
+		GalleryData   *GalleryData  `json:"gallery_data"`
+		ID            string        `json:"id"`
+		IsVideo       bool          `json:"is_video"`
 		LinkFlairText string        `json:"link_flair_text"`
 		Name          string        `json:"name"`
+		Media         *Media        `json:"media"`
 		MediaMetadata map[string]struct {
 			Status string `json:"status"`
 			E      string `json:"e"`
@@ -37,18 +49,36 @@ type (
 				U string `json:"u"`
 			} `json:"s"`
 		} `json:"media_metadata"`
-		Permalink    string `json:"permalink"`
-		Pinned       bool   `json:"pinned"`
-		PostHint     string `json:"post_hint"`
-		SelftextHTML string `json:"selftext_html"`
-		Stickied     bool   `json:"stickied"`
-		Title        string `json:"title"`
-		URL          string `json:"url"`
+		CrosspostParentList []Submission `json:"crosspost_parent_list"`
+		Permalink           string       `json:"permalink"`
+		Pinned              bool         `json:"pinned"`
+		PostHint            string       `json:"post_hint"`
+		Score               int          `json:"score"`
+		SecureMedia         *Media       `json:"secure_media"`
+		SelftextHTML        string       `json:"selftext_html"`
+		Stickied            bool         `json:"stickied"`
+		Title               string       `json:"title"`
+		UpvoteRatio         float64      `json:"upvote_ratio"`
+		URL                 string       `json:"url"`
+	}
+
+	GalleryData struct {
+		Items []struct {
+			MediaID string `json:"media_id"`
+		} `json:"items"`
+	}
+
+	Media struct {
+		RedditVideo *struct {
+			FallbackURL string `json:"fallback_url"`
+			IsGIF       bool   `json:"is_gif"`
+		} `json:"reddit_video"`
 	}
 
 	Subreddit struct {
 		Data struct {
 			Children []struct {
+				Kind string     `json:"kind"`
 				Data Submission `json:"data,omitempty"`
 			} `json:"children"`
 		} `json:"data"`
@@ -61,81 +91,294 @@ func init() {
 
 // New return reddit entity
 func New(entity crossposter.Entity) (crossposter.EntityInterface, error) {
-	cache, err := lru.New(10000)
-	return &Reddit{entity: &entity, published: cache}, err
+	for _, name := range entity.Sources {
+		if value := entity.Option("min_score", name); value != "" {
+			if _, err := strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("invalid min_score for %s: %v", name, err)
+			}
+		}
+		if value := entity.Option("min_upvote_ratio", name); value != "" {
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return nil, fmt.Errorf("invalid min_upvote_ratio for %s: %v", name, err)
+			}
+		}
+	}
+	reddit := &Reddit{entity: &entity}
+	if entity.Options["client_id"] != "" {
+		userAgent := entity.Options["user_agent"]
+		if userAgent == "" {
+			userAgent = defaultUserAgent
+		}
+		reddit.client = &oauthClient{
+			clientID:     entity.Options["client_id"],
+			clientSecret: entity.Options["client_secret"],
+			username:     entity.Options["user"],
+			password:     entity.Options["password"],
+			userAgent:    userAgent,
+			client:       &http.Client{Timeout: 30 * time.Second},
+		}
+	}
+	return reddit, nil
 }
 
 // Get reddit message
 func (reddit *Reddit) Get(lastUpdate time.Time) {
 	defer crossposter.WaitGroup.Done()
+	checkpoints := crossposter.NewCheckpoints(lastUpdate)
+	published, err := crossposter.NewPublished(10000, lastUpdate)
+	if err != nil {
+		log.WithFields(log.Fields{"type": reddit.entity.Type}).Error(err)
+		return
+	}
 
 	for {
 		for _, name := range reddit.entity.Sources {
 			redLogger := log.WithFields(log.Fields{"sub": name, "type": reddit.entity.Type})
 			redLogger.Info("Check subreddit updates")
 
-			posts := []crossposter.Post{}
-			var data Subreddit
-			url := fmt.Sprintf("https://www.reddit.com/r/%s.json", name)
-			err := utils.GetJSON(url, &data)
+			data, err := reddit.listing(name)
 			if err != nil {
 				redLogger.Error(err)
-			} else {
-				for _, sub := range data.Data.Children {
-					if !sub.Data.Pinned && !sub.Data.Stickied && sub.Data.LinkFlairText != "MOD POST" {
-						var mediaURLs []string
-						url := sub.Data.URL
-						if sub.Data.PostHint == "image" {
-							mediaURLs = append(mediaURLs, sub.Data.URL)
-							url = "https://www.reddit.com" + sub.Data.Permalink
-						} else {
-							for _, media := range sub.Data.MediaMetadata {
-								if media.E == "Image" {
-									mediaURLs = append(mediaURLs, html.UnescapeString(media.S.U))
-								}
-							}
-						}
-						text := html.UnescapeString(sub.Data.SelftextHTML)
-						if text == "" {
-							text = html.UnescapeString(sub.Data.BodyHTML)
-						}
-						text = strings.TrimPrefix(text, "<!-- SC_OFF -->")
-						text = strings.TrimSuffix(text, "<!-- SC_ON -->")
-						post := crossposter.Post{
-							Date:        time.Time(sub.Data.CreatedUTC),
-							URL:         url,
-							Author:      sub.Data.Author,
-							Title:       sub.Data.Title,
-							Text:        strings.TrimSpace(text),
-							Attachments: mediaURLs,
-							More:        true,
-						}
-						posts = append(posts, post)
-					}
+				continue
+			}
+
+			minScore, _ := strconv.Atoi(reddit.entity.Option("min_score", name))
+			minRatio, _ := strconv.ParseFloat(reddit.entity.Option("min_upvote_ratio", name), 64)
+			// Posts of sorted listings or passed thresholds may be older than checkpoint,
+			// so they are deduplicated only by already published ones
+			gated := minScore == 0 && minRatio == 0 && reddit.listingOrder(name) == "new"
+
+			subs := []Submission{}
+			for _, sub := range data.Data.Children {
+				if sub.Data.Pinned || sub.Data.Stickied || sub.Data.LinkFlairText == "MOD POST" {
+					continue
 				}
+				if sub.Data.Score < minScore || sub.Data.UpvoteRatio < minRatio {
+					continue
+				}
+				subs = append(subs, sub.Data)
+			}
 
-				sort.Slice(posts, func(i, j int) bool {
-					return posts[i].Date.Before(posts[j].Date)
-				})
-
-				for _, post := range posts {
-					if post.Date.After(lastUpdate) && !reddit.published.Contains(post.URL) {
-						lastUpdate = post.Date
-						reddit.published.Add(post.URL, nil)
-						for _, topic := range reddit.entity.Topics {
-							crossposter.Events.Publish(topic, post)
-							time.Sleep(time.Second * 5)
-						}
-					}
+			sort.Slice(subs, func(i, j int) bool {
+				return time.Time(subs[i].CreatedUTC).Before(time.Time(subs[j].CreatedUTC))
+			})
+
+			for _, sub := range subs {
+				post := reddit.submissionToPost(sub)
+				if gated && !checkpoints.IsNew(name, post.Date) {
+					continue
+				}
+				if !published.IsNew(name, post.URL, post.Date) {
+					continue
+				}
+				checkpoints.Update(name, post.Date)
+				reddit.addComments(&post, sub, name, redLogger)
+				for _, topic := range reddit.entity.Topics {
+					crossposter.Events.Publish(topic, post)
+					time.Sleep(time.Second * 5)
 				}
 			}
+			published.Initialize(name)
 		}
 		time.Sleep(time.Duration(reddit.entity.Wait) * time.Minute)
 	}
 }
 
-// Post reddit message
-func (reddit *Reddit) Post(post crossposter.Post) {}
+// listing of subreddit submissions sorted as configured for source
+func (reddit *Reddit) listing(name string) (Subreddit, error) {
+	var data Subreddit
+
+	params := url.Values{}
+	if limit := reddit.entity.Option("limit", name); limit != "" {
+		params.Set("limit", limit)
+	}
+	if period := reddit.entity.Option("time", name); period != "" {
+		params.Set("t", period)
+	}
+
+	err := reddit.getJSON(fmt.Sprintf("/r/%s/%s", name, reddit.listingOrder(name)), params, &data)
+	return data, err
+}
+
+// listingOrder of subreddit submissions configured for source
+func (reddit *Reddit) listingOrder(name string) string {
+	order := reddit.entity.Option("listing", name)
+	if order == "" {
+		order = "hot"
+	}
+	return order
+}
+
+// submissionToPost convert submission with its crosspost parent to post
+func (reddit *Reddit) submissionToPost(sub Submission) crossposter.Post {
+	permalink := "https://www.reddit.com" + sub.Permalink
+	content := sub
+	if len(sub.CrosspostParentList) > 0 {
+		content = sub.CrosspostParentList[0]
+	}
+
+	var mediaURLs []string
+	link := content.URL
+	media := content.SecureMedia
+	if media == nil {
+		media = content.Media
+	}
+	switch {
+	case media != nil && media.RedditVideo != nil && media.RedditVideo.FallbackURL != "":
+		mediaURLs = append(mediaURLs, html.UnescapeString(media.RedditVideo.FallbackURL))
+		link = permalink
+	case content.PostHint == "image":
+		mediaURLs = append(mediaURLs, content.URL)
+		link = permalink
+	default:
+		// Order of gallery items is kept only in gallery data
+		var ids []string
+		if content.GalleryData != nil {
+			for _, item := range content.GalleryData.Items {
+				ids = append(ids, item.MediaID)
+			}
+		} else {
+			for id := range content.MediaMetadata {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+		}
+		for _, id := range ids {
+			if media, ok := content.MediaMetadata[id]; ok && media.E == "Image" {
+				mediaURLs = append(mediaURLs, html.UnescapeString(media.S.U))
+			}
+		}
+	}
+	if strings.HasPrefix(link, "/r/") {
+		link = "https://www.reddit.com" + link
+	}
+
+	text := html.UnescapeString(content.SelftextHTML)
+	if text == "" {
+		text = html.UnescapeString(content.BodyHTML)
+	}
+	text = strings.TrimPrefix(text, "<!-- SC_OFF -->")
+	text = strings.TrimSuffix(text, "<!-- SC_ON -->")
+	text = strings.TrimSpace(text)
+
+	return crossposter.Post{
+		Date:        time.Time(sub.CreatedUTC),
+		URL:         link,
+		Author:      sub.Author,
+		Title:       sub.Title,
+		Text:        text,
+		Attachments: mediaURLs,
+		More:        true,
+	}
+}
+
+// addComments append top comments of submission to post text if configured
+func (reddit *Reddit) addComments(post *crossposter.Post, sub Submission, name string, logger *log.Entry) {
+	count, _ := strconv.Atoi(reddit.entity.Option("comments", name))
+	if count <= 0 {
+		return
+	}
+	comments, err := reddit.topComments(sub.ID, count)
+	if err != nil {
+		logger.Warnf("Can't get comments of https://www.reddit.com%s: %s", sub.Permalink, err)
+	}
+	for _, comment := range comments {
+		post.Text += fmt.Sprintf("\n<blockquote><b>%s</b>: %s</blockquote>", comment.Author,
+			strings.TrimSpace(html.UnescapeString(comment.BodyHTML)))
+	}
+}
+
+// topComments of submission
+func (reddit *Reddit) topComments(id string, count int) ([]Submission, error) {
+	var data []Subreddit
+	params := url.Values{}
+	params.Set("sort", "top")
+	params.Set("depth", "1")
+	params.Set("limit", strconv.Itoa(count))
+	err := reddit.getJSON("/comments/"+id, params, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	var comments []Submission
+	if len(data) > 1 {
+		for _, child := range data[1].Data.Children {
+			if child.Kind == "t1" && len(comments) < count {
+				comments = append(comments, child.Data)
+			}
+		}
+	}
+	return comments, nil
+}
+
+// getJSON from Reddit API with OAuth if configured or anonymously
+func (reddit *Reddit) getJSON(path string, params url.Values, target interface{}) error {
+	if reddit.client != nil {
+		return reddit.client.request("GET", path+"?"+params.Encode(), nil, target)
+	}
+	return utils.GetJSON("https://www.reddit.com"+path+".json?"+params.Encode(), target)
+}
+
+// Post submit message to subreddits
+func (reddit *Reddit) Post(post crossposter.Post) {
+	if reddit.client == nil {
+		log.WithFields(log.Fields{"type": reddit.entity.Type}).Error("OAuth credentials not configured")
+		return
+	}
+
+	title := post.Title
+	if title == "" {
+		title = strings.SplitN(htmlToText(post.Text), "\n", 2)[0]
+	}
+	title = utils.TruncateText(title, maxTitleLength)
+
+	text := htmlToMarkdown(post.Text)
+	if post.URL != "" && (post.More || post.Title == "") {
+		text += "\n\n" + post.URL
+	}
+
+	// Only images can be submitted as image or gallery
+	var images []string
+	for _, attach := range post.Attachments {
+		if mediaType := utils.MediaType(attach); !strings.HasPrefix(mediaType, "image/") {
+			log.WithFields(log.Fields{"attachment": attach, "type": reddit.entity.Type}).Warnf("Skip attachment of %q type", mediaType)
+			continue
+		}
+		images = append(images, attach)
+		if len(images) == maxGalleryItems {
+			break
+		}
+	}
+
+	for _, subreddit := range reddit.entity.Destinations {
+		redLogger := log.WithFields(log.Fields{"sub": subreddit, "type": reddit.entity.Type})
+
+		sub := submission{url: post.URL, attachments: images}
+		switch kind := reddit.entity.Option("kind", subreddit); {
+		case kind != "" && kind != "auto":
+			sub.kind = kind
+		case len(sub.attachments) > 1:
+			sub.kind = "gallery"
+		case len(sub.attachments) == 1:
+			sub.kind = "image"
+		case text == "" && post.URL != "":
+			sub.kind = "link"
+		default:
+			sub.kind = "self"
+		}
+		if (sub.kind == "image" || sub.kind == "gallery") && len(sub.attachments) == 0 ||
+			sub.kind == "link" && sub.url == "" {
+			sub.kind = "self"
+		}
+
+		link, err := reddit.submit(subreddit, title, text, sub)
+		if err != nil {
+			redLogger.Error(err)
+		} else {
+			redLogger.Printf("Submitted %s %s", sub.kind, link)
+		}
+	}
+}
 
 // Handler reddit message
 func (reddit *Reddit) Handler(w http.ResponseWriter, r *http.Request) {}
@@ -0,0 +1,218 @@
+package reddit
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	maxTitleLength  = 300
+	maxGalleryItems = 20
+	maxMediaSize    = 20 << 20
+)
+
+type submitResponse struct {
+	JSON struct {
+		Errors [][]interface{} `json:"errors"`
+		Data   struct {
+			URL string `json:"url"`
+		} `json:"data"`
+	} `json:"json"`
+}
+
+func (r submitResponse) err() error {
+	if len(r.JSON.Errors) == 0 {
+		return nil
+	}
+	var messages []string
+	for _, e := range r.JSON.Errors {
+		messages = append(messages, fmt.Sprint(e...))
+	}
+	return fmt.Errorf("submit failed: %s", strings.Join(messages, "; "))
+}
+
+type galleryItem struct {
+	MediaID     string `json:"media_id"`
+	Caption     string `json:"caption"`
+	OutboundURL string `json:"outbound_url"`
+}
+
+// submitOptions return common parameters of submission for subreddit
+func (reddit *Reddit) submitOptions(subreddit string) url.Values {
+	v := url.Values{}
+	v.Set("sr", subreddit)
+	v.Set("api_type", "json")
+	v.Set("resubmit", "true")
+	if flair := reddit.entity.Option("flair_id", subreddit); flair != "" {
+		v.Set("flair_id", flair)
+	}
+	if flair := reddit.entity.Option("flair_text", subreddit); flair != "" {
+		v.Set("flair_text", flair)
+	}
+	for _, name := range []string{"nsfw", "spoiler"} {
+		if ok, _ := strconv.ParseBool(reddit.entity.Option(name, subreddit)); ok {
+			v.Set(name, "true")
+		}
+	}
+	return v
+}
+
+// submit post to subreddit choosing kind of submission
+func (reddit *Reddit) submit(subreddit, title string, text string, post submission) (string, error) {
+	v := reddit.submitOptions(subreddit)
+	v.Set("title", title)
+
+	var response submitResponse
+	switch post.kind {
+	case "gallery":
+		var items []galleryItem
+		for _, attach := range post.attachments {
+			assetID, _, err := reddit.uploadMedia(attach)
+			if err != nil {
+				log.WithFields(log.Fields{"attachment": attach, "sub": subreddit}).Warnf("Skip gallery item: %s", err)
+				continue
+			}
+			items = append(items, galleryItem{MediaID: assetID, OutboundURL: post.url})
+		}
+		if len(items) == 0 {
+			return "", fmt.Errorf("no gallery items uploaded")
+		}
+		body := map[string]interface{}{
+			"sr":              subreddit,
+			"title":           title,
+			"items":           items,
+			"api_type":        "json",
+			"show_error_list": true,
+			"nsfw":            v.Get("nsfw") == "true",
+			"spoiler":         v.Get("spoiler") == "true",
+			"flair_id":        v.Get("flair_id"),
+			"flair_text":      v.Get("flair_text"),
+		}
+		err := reddit.client.request("POST", "/api/submit_gallery_post.json", body, &response)
+		if err != nil {
+			return "", err
+		}
+	case "image":
+		_, mediaURL, err := reddit.uploadMedia(post.attachments[0])
+		if err != nil {
+			return "", err
+		}
+		v.Set("kind", "image")
+		v.Set("url", mediaURL)
+		err = reddit.client.request("POST", "/api/submit", v, &response)
+		if err != nil {
+			return "", err
+		}
+	case "link":
+		v.Set("kind", "link")
+		v.Set("url", post.url)
+		err := reddit.client.request("POST", "/api/submit", v, &response)
+		if err != nil {
+			return "", err
+		}
+	default:
+		v.Set("kind", "self")
+		v.Set("text", text)
+		err := reddit.client.request("POST", "/api/submit", v, &response)
+		if err != nil {
+			return "", err
+		}
+	}
+	return response.JSON.Data.URL, response.err()
+}
+
+// uploadMedia to Reddit storage and return asset ID with media URL
+func (reddit *Reddit) uploadMedia(attach string) (string, string, error) {
+	res, err := http.Get(attach)
+	if err != nil {
+		return "", "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("bad status: %s", res.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, maxMediaSize+1))
+	if err != nil {
+		return "", "", err
+	}
+	if len(data) > maxMediaSize {
+		return "", "", fmt.Errorf("media %s is too large", attach)
+	}
+
+	mimeType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if !strings.HasPrefix(mimeType, "image/") && !strings.HasPrefix(mimeType, "video/") {
+		mimeType, _, _ = mime.ParseMediaType(http.DetectContentType(data))
+	}
+	u, err := url.Parse(attach)
+	if err != nil {
+		return "", "", err
+	}
+	filename := path.Base(u.Path)
+	if path.Ext(filename) == "" {
+		if exts, _ := mime.ExtensionsByType(mimeType); len(exts) > 0 {
+			filename += exts[0]
+		}
+	}
+
+	var lease struct {
+		Args struct {
+			Action string `json:"action"`
+			Fields []struct {
+				Name  string `json:"name"`
+				Value string `json:"value"`
+			} `json:"fields"`
+		} `json:"args"`
+		Asset struct {
+			AssetID string `json:"asset_id"`
+		} `json:"asset"`
+	}
+	v := url.Values{}
+	v.Set("filepath", filename)
+	v.Set("mimetype", mimeType)
+	err = reddit.client.request("POST", "/api/media/asset.json", v, &lease)
+	if err != nil {
+		return "", "", err
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	key := ""
+	for _, field := range lease.Args.Fields {
+		writer.WriteField(field.Name, field.Value)
+		if field.Name == "key" {
+			key = field.Value
+		}
+	}
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return "", "", err
+	}
+	part.Write(data)
+	writer.Close()
+
+	action := lease.Args.Action
+	if strings.HasPrefix(action, "//") {
+		action = "https:" + action
+	}
+	upload, err := http.Post(action, writer.FormDataContentType(), &body)
+	if err != nil {
+		return "", "", err
+	}
+	defer upload.Body.Close()
+	if upload.StatusCode >= 300 {
+		return "", "", fmt.Errorf("media upload failed: %s", upload.Status)
+	}
+
+	return lease.Asset.AssetID, action + "/" + key, nil
+}
@@ -0,0 +1,126 @@
+package rss
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/n0madic/crossposter/utils"
+)
+
+// Selectors of article content in popular site engines
+var articleSelectors = []string{
+	"[itemprop=articleBody]",
+	"article .entry-content",
+	"article .post-content",
+	".entry-content",
+	".post-content",
+	".article-content",
+	".article-body",
+	"article",
+	"main",
+}
+
+// Elements not belonging to article content
+const junkSelector = "script, style, noscript, iframe, form, nav, aside, footer, header, button, " +
+	".share, .social, .comments, .related, .advert, .ads"
+
+type article struct {
+	html  string
+	image string
+}
+
+// extractArticle fetch page and extract main article content and lead image
+func (rss *RSS) extractArticle(link, source string) (article, error) {
+	selector := rss.entity.Option("selector", source)
+	if cached, ok := rss.articles.Get(selector + " " + link); ok {
+		return cached.(article), nil
+	}
+
+	charset := rss.entity.Option("charset", source)
+	if charset == "" {
+		charset = "utf-8"
+	}
+	doc, err := utils.NewDocumentToUTF8(link, charset)
+	if err != nil {
+		return article{}, err
+	}
+	base, err := url.Parse(link)
+	if err != nil {
+		return article{}, err
+	}
+
+	var content *goquery.Selection
+	if selector != "" {
+		content = doc.Find(selector).First()
+	} else {
+		for _, sel := range articleSelectors {
+			if found := doc.Find(sel).First(); found.Length() > 0 && len(strings.TrimSpace(found.Text())) > 0 {
+				content = found
+				break
+			}
+		}
+		if content == nil {
+			content = densestBlock(doc)
+		}
+	}
+	if content == nil || content.Length() == 0 {
+		return article{}, fmt.Errorf("article content not found")
+	}
+
+	result := article{}
+	imageSelector := rss.entity.Option("image_selector", source)
+	if imageSelector != "" {
+		result.image = doc.Find(imageSelector).First().AttrOr("src", "")
+		content.Find(imageSelector).Remove()
+	}
+	if result.image == "" {
+		result.image = doc.Find(`meta[property="og:image"]`).AttrOr("content", "")
+	}
+	if result.image != "" {
+		if u, err := base.Parse(result.image); err == nil {
+			result.image = u.String()
+		}
+	}
+
+	content.Find(junkSelector).Remove()
+	resolveLinks(content, base)
+
+	html, err := content.Html()
+	if err != nil {
+		return article{}, err
+	}
+	result.html = strings.TrimSpace(html)
+
+	rss.articles.Add(selector+" "+link, result)
+	return result, nil
+}
+
+// densestBlock find block with largest amount of paragraph text
+func densestBlock(doc *goquery.Document) *goquery.Selection {
+	var best *goquery.Selection
+	bestLength := 0
+	doc.Find("div, section, td").Each(func(i int, sel *goquery.Selection) {
+		length := 0
+		sel.ChildrenFiltered("p").Each(func(i int, p *goquery.Selection) {
+			length += len(strings.TrimSpace(p.Text()))
+		})
+		if length > bestLength {
+			bestLength = length
+			best = sel
+		}
+	})
+	return best
+}
+
+// resolveLinks make relative links and images absolute
+func resolveLinks(sel *goquery.Selection, base *url.URL) {
+	for _, attr := range []string{"href", "src", "data-src"} {
+		sel.Find("[" + attr + "]").Each(func(i int, s *goquery.Selection) {
+			if u, err := base.Parse(s.AttrOr(attr, "")); err == nil {
+				s.SetAttr(attr, u.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package rss
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/feeds"
+)
+
+const (
+	atomNS   = "http://www.w3.org/2005/Atom"
+	mediaNS  = "http://search.yahoo.com/mrss/"
+	itunesNS = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+)
+
+// Feed formats with content types
+var contentTypes = map[string]string{
+	"rss":  "application/rss+xml; charset=utf-8",
+	"atom": "application/atom+xml; charset=utf-8",
+	"json": "application/feed+json; charset=utf-8",
+}
+
+type rssFeedXML struct {
+	XMLName          xml.Name `xml:"rss"`
+	Version          string   `xml:"version,attr"`
+	ContentNamespace string   `xml:"xmlns:content,attr"`
+	AtomNamespace    string   `xml:"xmlns:atom,attr"`
+	MediaNamespace   string   `xml:"xmlns:media,attr"`
+	ItunesNamespace  string   `xml:"xmlns:itunes,attr,omitempty"`
+	Channel          *rssChannel
+}
+
+type rssChannel struct {
+	*feeds.RssFeed
+	AtomLink       *atomLink
+	ItunesAuthor   string       `xml:"itunes:author,omitempty"`
+	ItunesSummary  string       `xml:"itunes:summary,omitempty"`
+	ItunesExplicit string       `xml:"itunes:explicit,omitempty"`
+	ItunesImage    *itunesImage `xml:"itunes:image,omitempty"`
+	ItunesCategory *itunesCategory
+	Items          []*rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	*feeds.RssItem
+	MediaContent   []mediaContent `xml:"media:content"`
+	MediaThumbnail *mediaThumbnail
+	ItunesAuthor   string `xml:"itunes:author,omitempty"`
+	ItunesSummary  string `xml:"itunes:summary,omitempty"`
+}
+
+type atomLink struct {
+	XMLName xml.Name `xml:"atom:link"`
+	Href    string   `xml:"href,attr"`
+	Rel     string   `xml:"rel,attr"`
+	Type    string   `xml:"type,attr"`
+}
+
+type mediaContent struct {
+	URL      string `xml:"url,attr"`
+	Type     string `xml:"type,attr,omitempty"`
+	Medium   string `xml:"medium,attr,omitempty"`
+	FileSize int64  `xml:"fileSize,attr,omitempty"`
+}
+
+type mediaThumbnail struct {
+	XMLName xml.Name `xml:"media:thumbnail"`
+	URL     string   `xml:"url,attr"`
+}
+
+type itunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+type itunesCategory struct {
+	XMLName xml.Name `xml:"itunes:category"`
+	Text    string   `xml:"text,attr"`
+}
+
+type atomFeedXML struct {
+	*feeds.AtomFeed
+	Links []feeds.AtomLink
+}
+
+// render feed in format with link to itself
+func render(f *feed, format, selfURL string) ([]byte, error) {
+	switch format {
+	case "atom":
+		return renderAtom(f, selfURL)
+	case "json":
+		return renderJSON(f, selfURL)
+	}
+	return renderRSS(f, selfURL)
+}
+
+func renderRSS(f *feed, selfURL string) ([]byte, error) {
+	rss := (&feeds.Rss{Feed: f.Feed}).RssFeed()
+	channel := &rssChannel{
+		RssFeed:  rss,
+		AtomLink: &atomLink{Href: selfURL, Rel: "self", Type: "application/rss+xml"},
+	}
+	feedXML := &rssFeedXML{
+		Version:          "2.0",
+		ContentNamespace: "http://purl.org/rss/1.0/modules/content/",
+		AtomNamespace:    atomNS,
+		MediaNamespace:   mediaNS,
+		Channel:          channel,
+	}
+	if f.podcast {
+		feedXML.ItunesNamespace = itunesNS
+		channel.ItunesAuthor = f.Title
+		if f.Author != nil && f.Author.Name != "" {
+			channel.ItunesAuthor = f.Author.Name
+		}
+		channel.ItunesSummary = f.Description
+		channel.ItunesExplicit = f.explicit
+		if f.Image != nil {
+			channel.ItunesImage = &itunesImage{Href: f.Image.Url}
+		}
+		if f.category != "" {
+			channel.ItunesCategory = &itunesCategory{Text: f.category}
+		}
+	}
+
+	for i, ri := range rss.Items {
+		item := f.Items[i]
+		attachments := f.media[item.Id]
+		x := &rssItem{RssItem: ri}
+		if enc := enclosure(attachments); enc != nil {
+			x.Enclosure = &feeds.RssEnclosure{
+				Url:    enc.URL,
+				Type:   enc.Type,
+				Length: strconv.FormatInt(enc.Length, 10),
+			}
+		}
+		for _, m := range attachments {
+			x.MediaContent = append(x.MediaContent, mediaContent{
+				URL:      m.URL,
+				Type:     m.Type,
+				Medium:   m.medium(),
+				FileSize: m.Length,
+			})
+			if x.MediaThumbnail == nil && m.medium() == "image" {
+				x.MediaThumbnail = &mediaThumbnail{URL: m.URL}
+			}
+		}
+		if f.podcast && hasAudio(attachments) {
+			if item.Author != nil {
+				x.ItunesAuthor = item.Author.Name
+			}
+			x.ItunesSummary = item.Title
+		}
+		channel.Items = append(channel.Items, x)
+	}
+	rss.Items = nil
+
+	return marshalXML(feedXML)
+}
+
+func renderAtom(f *feed, selfURL string) ([]byte, error) {
+	atom := (&feeds.Atom{Feed: f.Feed}).AtomFeed()
+	if atom.Id == "" {
+		atom.Id = selfURL
+	}
+	links := []feeds.AtomLink{{Href: selfURL, Rel: "self", Type: "application/atom+xml"}}
+	if atom.Link.Href != "" {
+		links = append(links, feeds.AtomLink{Href: atom.Link.Href, Rel: "alternate"})
+	}
+	atom.Link = nil
+
+	for i, entry := range atom.Entries {
+		for _, m := range f.media[f.Items[i].Id] {
+			entry.Links = append(entry.Links, feeds.AtomLink{
+				Href:   m.URL,
+				Rel:    "enclosure",
+				Type:   m.Type,
+				Length: strconv.FormatInt(m.Length, 10),
+			})
+		}
+	}
+
+	return marshalXML(&atomFeedXML{AtomFeed: atom, Links: links})
+}
+
+// jsonAttachment of JSON feed item with size of files larger than 2 GiB
+type jsonAttachment struct {
+	URL      string `json:"url"`
+	MIMEType string `json:"mime_type"`
+	Size     int64  `json:"size_in_bytes,omitempty"`
+}
+
+// jsonItem of JSON feed with own attachments
+type jsonItem struct {
+	*feeds.JSONItem
+	Attachments []jsonAttachment `json:"attachments,omitempty"`
+}
+
+// jsonFeed with own items
+type jsonFeed struct {
+	*feeds.JSONFeed
+	Items []*jsonItem `json:"items,omitempty"`
+}
+
+func renderJSON(f *feed, selfURL string) ([]byte, error) {
+	feed := jsonFeed{JSONFeed: (&feeds.JSON{Feed: f.Feed}).JSONFeed()}
+	feed.FeedUrl = selfURL
+	for i, item := range feed.JSONFeed.Items {
+		ji := &jsonItem{JSONItem: item}
+		for _, m := range f.media[f.Items[i].Id] {
+			ji.Attachments = append(ji.Attachments, jsonAttachment{
+				URL:      m.URL,
+				MIMEType: m.Type,
+				Size:     m.Length,
+			})
+			if item.Image == "" && m.medium() == "image" {
+				item.Image = m.URL
+			}
+		}
+		feed.Items = append(feed.Items, ji)
+	}
+	return json.MarshalIndent(feed, "", "  ")
+}
+
+func marshalXML(v interface{}) ([]byte, error) {
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// requestURL return absolute URL of request
+func requestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = strings.TrimSpace(strings.Split(proto, ",")[0])
+	}
+	host := r.Host
+	if forwarded := r.Header.Get("X-Forwarded-Host"); forwarded != "" {
+		host = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	return scheme + "://" + host + r.URL.Path
+}
+
+// feedFormat detect format from request path
+func feedFormat(path string) string {
+	for format := range contentTypes {
+		if strings.HasPrefix(path, "/"+format+"/") {
+			return format
+		}
+	}
+	return "rss"
+}
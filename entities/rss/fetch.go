@@ -0,0 +1,141 @@
+package rss
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	rssfeed "github.com/mmcdole/gofeed/rss"
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// source state between checks
+type sourceState struct {
+	etag         string
+	lastModified string
+	nextCheck    time.Time
+	skipHours    []int
+	initialized  bool
+}
+
+// rssTranslator keep TTL and skip hours of RSS channel in custom fields
+type rssTranslator struct {
+	gofeed.DefaultRSSTranslator
+}
+
+func (t *rssTranslator) Translate(feed interface{}) (*gofeed.Feed, error) {
+	result, err := t.DefaultRSSTranslator.Translate(feed)
+	if err != nil {
+		return nil, err
+	}
+	if rss, ok := feed.(*rssfeed.Feed); ok {
+		if result.Custom == nil {
+			result.Custom = make(map[string]string)
+		}
+		result.Custom["ttl"] = rss.TTL
+		result.Custom["skipHours"] = strings.Join(rss.SkipHours, ",")
+	}
+	return result, nil
+}
+
+// fetch feed with conditional request, returns nil feed if not modified
+func fetch(fp *gofeed.Parser, source string, state *sourceState) (*gofeed.Feed, error) {
+	req, err := http.NewRequest("GET", source, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Crossposter/1.0")
+	if state.etag != "" {
+		req.Header.Set("If-None-Match", state.etag)
+	}
+	if state.lastModified != "" {
+		req.Header.Set("If-Modified-Since", state.lastModified)
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return nil, nil
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		if wait := retryAfter(res.Header.Get("Retry-After")); wait > 0 {
+			state.nextCheck = time.Now().Add(wait)
+		}
+		return nil, fmt.Errorf("bad status: %s", res.Status)
+	default:
+		return nil, fmt.Errorf("bad status: %s", res.Status)
+	}
+
+	sourceFeed, err := fp.Parse(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	state.etag = res.Header.Get("ETag")
+	state.lastModified = res.Header.Get("Last-Modified")
+	if ttl, err := strconv.Atoi(sourceFeed.Custom["ttl"]); err == nil && ttl > 0 {
+		state.nextCheck = time.Now().Add(time.Duration(ttl) * time.Minute)
+	}
+	state.skipHours = nil
+	for _, hour := range strings.Split(sourceFeed.Custom["skipHours"], ",") {
+		if h, err := strconv.Atoi(strings.TrimSpace(hour)); err == nil {
+			state.skipHours = append(state.skipHours, h)
+		}
+	}
+	return sourceFeed, nil
+}
+
+// skipped check if source should not be checked now
+func (state *sourceState) skipped(now time.Time) bool {
+	if now.Before(state.nextCheck) {
+		return true
+	}
+	for _, hour := range state.skipHours {
+		if now.UTC().Hour() == hour {
+			return true
+		}
+	}
+	return false
+}
+
+// retryAfter parse Retry-After header value
+func retryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+// itemDate return published or updated date of item
+func itemDate(item *gofeed.Item) *time.Time {
+	if item.PublishedParsed != nil {
+		return item.PublishedParsed
+	}
+	return item.UpdatedParsed
+}
+
+// itemGUID return unique identifier of item
+func itemGUID(item *gofeed.Item) string {
+	if item.GUID != "" {
+		return item.GUID
+	}
+	if item.Link != "" {
+		return item.Link
+	}
+	sum := sha1.Sum([]byte(item.Title + item.Description))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,100 @@
+package rss
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mediaClient for probing of attachments, time limited to not block posting
+var mediaClient = &http.Client{Timeout: 5 * time.Second}
+
+// media attachment of feed item
+type media struct {
+	URL    string `json:"url"`
+	Type   string `json:"type"`
+	Length int64  `json:"length"`
+}
+
+// medium return Media RSS medium of attachment
+func (m media) medium() string {
+	switch {
+	case strings.HasPrefix(m.Type, "image/"):
+		return "image"
+	case strings.HasPrefix(m.Type, "audio/"):
+		return "audio"
+	case strings.HasPrefix(m.Type, "video/"):
+		return "video"
+	}
+	return "document"
+}
+
+// probeMedia detect type and length of attachment
+func probeMedia(attach string) media {
+	m := media{URL: attach}
+
+	res, err := mediaClient.Head(attach)
+	if err == nil {
+		res.Body.Close()
+		if res.StatusCode == http.StatusOK {
+			m.Type, _, _ = mime.ParseMediaType(res.Header.Get("Content-Type"))
+			m.Length, _ = strconv.ParseInt(res.Header.Get("Content-Length"), 10, 64)
+		}
+	}
+
+	if m.Type == "" || m.Type == "application/octet-stream" {
+		if u, err := url.Parse(attach); err == nil {
+			if t := mime.TypeByExtension(strings.ToLower(path.Ext(u.Path))); t != "" {
+				m.Type, _, _ = mime.ParseMediaType(t)
+			}
+		}
+	}
+	if m.Type == "" {
+		m.Type = "application/octet-stream"
+	}
+	return m
+}
+
+// probeAll detect attachments concurrently keeping their order
+func probeAll(attachments []string) []media {
+	result := make([]media, len(attachments))
+	var wg sync.WaitGroup
+	for i, attach := range attachments {
+		wg.Add(1)
+		go func(i int, attach string) {
+			defer wg.Done()
+			result[i] = probeMedia(attach)
+		}(i, attach)
+	}
+	wg.Wait()
+	return result
+}
+
+// enclosure choose attachment for single enclosure of item,
+// audio and video are preferred
+func enclosure(attachments []media) *media {
+	for i, m := range attachments {
+		if m.medium() == "audio" || m.medium() == "video" {
+			return &attachments[i]
+		}
+	}
+	if len(attachments) > 0 {
+		return &attachments[0]
+	}
+	return nil
+}
+
+// hasAudio check if any attachment is audio
+func hasAudio(attachments []media) bool {
+	for _, m := range attachments {
+		if m.medium() == "audio" {
+			return true
+		}
+	}
+	return false
+}
@@ -1,13 +1,20 @@
 package rss
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/feeds"
+	lru "github.com/hashicorp/golang-lru"
 	"github.com/mmcdole/gofeed"
 	"github.com/n0madic/crossposter"
 	"github.com/n0madic/crossposter/utils"
@@ -15,12 +22,15 @@ import (
 )
 
 const maxTitleLength = 50
-const maxItemsInFeed = 10
+const defaultMaxItems = 10
 
 // RSS entity
 type RSS struct {
-	entity *crossposter.Entity
-	feed   *feeds.Feed
+	entity       *crossposter.Entity
+	destinations map[string]*feed
+	articles     *lru.Cache
+	published    *lru.Cache
+	mutex        sync.RWMutex
 }
 
 func init() {
@@ -29,16 +39,71 @@ func init() {
 
 // New run RSS entity
 func New(entity crossposter.Entity) (crossposter.EntityInterface, error) {
+	articles, err := lru.New(1000)
+	if err != nil {
+		return nil, err
+	}
+	published, err := lru.New(10000)
+	if err != nil {
+		return nil, err
+	}
 	rss := &RSS{
-		entity: &entity,
-		feed: &feeds.Feed{
-			Title:       entity.Options["title"],
-			Description: entity.Description,
-			Link:        &feeds.Link{Href: entity.Options["link"]},
-		},
+		entity:       &entity,
+		destinations: make(map[string]*feed),
+		articles:     articles,
+		published:    published,
 	}
 	for _, destination := range entity.Destinations {
-		http.HandleFunc("/rss/"+destination, rss.Handler)
+		f := &feed{
+			Feed: &feeds.Feed{
+				Title:       entity.Option("title", destination),
+				Description: entity.Description,
+				Link:        &feeds.Link{Href: entity.Option("link", destination)},
+			},
+			media:    make(map[string][]media),
+			maxItems: defaultMaxItems,
+			file:     storageFile(entity.Option("storage", destination), destination),
+			explicit: entity.Option("explicit", destination),
+			category: entity.Option("category", destination),
+		}
+		f.podcast, _ = strconv.ParseBool(entity.Option("podcast", destination))
+		if author := entity.Option("author", destination); author != "" {
+			f.Author = &feeds.Author{Name: author}
+		}
+		if image := entity.Option("image", destination); image != "" {
+			f.Image = &feeds.Image{Url: image, Title: f.Title, Link: f.Link.Href}
+		}
+		if f.podcast && f.explicit == "" {
+			f.explicit = "false"
+		}
+		if maxItems := entity.Option("max_items", destination); maxItems != "" {
+			n, err := strconv.Atoi(maxItems)
+			if err != nil {
+				return nil, fmt.Errorf("invalid max_items for %s: %v", destination, err)
+			}
+			f.maxItems = n
+		}
+		if maxAge := entity.Option("max_age", destination); maxAge != "" {
+			d, err := time.ParseDuration(maxAge)
+			if err != nil {
+				return nil, fmt.Errorf("invalid max_age for %s: %v", destination, err)
+			}
+			f.maxAge = d
+		}
+		if f.file != "" {
+			err := os.MkdirAll(filepath.Dir(f.file), 0755)
+			if err != nil {
+				return nil, err
+			}
+			err = f.load()
+			if err != nil {
+				return nil, fmt.Errorf("can't load feed %s: %v", destination, err)
+			}
+		}
+		rss.destinations[destination] = f
+		for format := range contentTypes {
+			http.HandleFunc("/"+format+"/"+destination, rss.Handler)
+		}
 	}
 	return rss, nil
 }
@@ -47,60 +112,110 @@ func New(entity crossposter.Entity) (crossposter.EntityInterface, error) {
 func (rss *RSS) Get(lastUpdate time.Time) {
 	defer crossposter.WaitGroup.Done()
 	fp := gofeed.NewParser()
+	fp.RSSTranslator = &rssTranslator{}
+	states := make(map[string]*sourceState)
 
 	for {
 		for _, source := range rss.entity.Sources {
 			rssLogger := log.WithFields(log.Fields{"source": source, "type": rss.entity.Type})
+			state, ok := states[source]
+			if !ok {
+				state = &sourceState{}
+				states[source] = state
+			}
+			if state.skipped(time.Now()) {
+				rssLogger.Debug("Skip check until ", state.nextCheck)
+				continue
+			}
+
 			rssLogger.Println("Check updates")
-			sourceFeed, err := fp.ParseURL(source)
+			sourceFeed, err := fetch(fp, source, state)
 			if err != nil {
 				rssLogger.Error(err)
-			} else {
-				if len(sourceFeed.Items) > 0 && sourceFeed.Items[0].PublishedParsed == nil {
-					rssLogger.Error(fmt.Errorf("published datetime not found"))
+				continue
+			}
+			if sourceFeed == nil {
+				rssLogger.Debug("Not modified")
+				continue
+			}
+
+			var dated, undated []*gofeed.Item
+			for _, item := range sourceFeed.Items {
+				if rss.published.Contains(itemGUID(item)) {
 					continue
 				}
+				if itemDate(item) != nil {
+					dated = append(dated, item)
+				} else {
+					// Feeds without dates usually list the newest items first
+					undated = append([]*gofeed.Item{item}, undated...)
+				}
+			}
+			sort.Slice(dated, func(i, j int) bool {
+				return itemDate(dated[i]).Before(*itemDate(dated[j]))
+			})
 
-				sort.Slice(sourceFeed.Items, func(i, j int) bool {
-					return sourceFeed.Items[i].PublishedParsed.Before(*sourceFeed.Items[j].PublishedParsed)
-				})
-
-				mediaURLs := []string{}
-				for _, item := range sourceFeed.Items {
-					if item.PublishedParsed.After(lastUpdate) {
-						lastUpdate = *item.PublishedParsed
-						if item.Image != nil && item.Image.URL != "" {
-							mediaURLs = append(mediaURLs, item.Image.URL)
-						}
-						for _, enclosure := range item.Enclosures {
-							if strings.HasPrefix(enclosure.Type, "image/") {
-								mediaURLs = append(mediaURLs, enclosure.URL)
-							}
-						}
-						author := ""
-						if item.Author != nil {
-							author = item.Author.Name
-						}
-						post := crossposter.Post{
-							Date:        *item.PublishedParsed,
-							URL:         item.Link,
-							Author:      author,
-							Title:       item.Title,
-							Text:        item.Description,
-							Attachments: mediaURLs,
-							More:        false,
-						}
-						for _, topic := range rss.entity.Topics {
-							crossposter.Events.Publish(topic, post)
-						}
-					}
+			for _, item := range append(dated, undated...) {
+				rss.published.Add(itemGUID(item), nil)
+				// On first check only items dated after last update are new,
+				// later any unseen item is new
+				date := itemDate(item)
+				if !state.initialized && (date == nil || !date.After(lastUpdate)) {
+					continue
+				}
+				post := rss.itemToPost(item, source, rssLogger)
+				for _, topic := range rss.entity.Topics {
+					crossposter.Events.Publish(topic, post)
 				}
 			}
+			state.initialized = true
 		}
 		time.Sleep(time.Duration(rss.entity.Wait) * time.Minute)
 	}
 }
 
+// itemToPost convert feed item to post
+func (rss *RSS) itemToPost(item *gofeed.Item, source string, logger *log.Entry) crossposter.Post {
+	mediaURLs := []string{}
+	if item.Image != nil && item.Image.URL != "" {
+		mediaURLs = append(mediaURLs, item.Image.URL)
+	}
+	for _, enclosure := range item.Enclosures {
+		if strings.HasPrefix(enclosure.Type, "image/") {
+			mediaURLs = append(mediaURLs, enclosure.URL)
+		}
+	}
+	author := ""
+	if item.Author != nil {
+		author = item.Author.Name
+	}
+	date := time.Now()
+	if itemDate(item) != nil {
+		date = *itemDate(item)
+	}
+	post := crossposter.Post{
+		Date:        date,
+		URL:         item.Link,
+		Author:      author,
+		Title:       item.Title,
+		Text:        item.Description,
+		Attachments: mediaURLs,
+		More:        false,
+	}
+	if fulltext, _ := strconv.ParseBool(rss.entity.Option("fulltext", source)); fulltext && item.Link != "" {
+		article, err := rss.extractArticle(item.Link, source)
+		if err != nil {
+			logger.Warnf("Can't extract article %s: %s", item.Link, err)
+		} else {
+			post.Text = article.html
+			if article.image != "" && !utils.StringInSlice(article.image, post.Attachments) {
+				post.Attachments = append([]string{article.image}, post.Attachments...)
+			}
+		}
+	}
+	return post
+}
+
 // Post add item to RSS feed
 func (rss *RSS) Post(post crossposter.Post) {
 	title := post.Title
@@ -109,38 +224,80 @@ func (rss *RSS) Post(post crossposter.Post) {
 	}
 
 	description := post.Text
-	for _, attach := range post.Attachments {
-		description += fmt.Sprintf(`<br><img src="%s" />`, attach)
+	attachments := probeAll(post.Attachments)
+	for _, m := range attachments {
+		if m.medium() == "image" {
+			description += fmt.Sprintf(`<br><img src="%s" />`, m.URL)
+		}
 	}
 	if post.More || title == "" {
 		description += " " + post.URL
 	}
 
-	if len(rss.feed.Items) == maxItemsInFeed {
-		rss.feed.Items = rss.feed.Items[1:]
-	}
+	rss.mutex.Lock()
+	defer rss.mutex.Unlock()
 
-	rss.feed.Add(&feeds.Item{
-		Title:       title,
-		Link:        &feeds.Link{Href: post.URL},
-		Description: strings.TrimSpace(description),
-		Author:      &feeds.Author{Name: post.Author},
-		Created:     post.Date,
-	})
+	for destination, f := range rss.destinations {
+		f.add(&feeds.Item{
+			Title:       title,
+			Link:        &feeds.Link{Href: post.URL},
+			Description: strings.TrimSpace(description),
+			Author:      &feeds.Author{Name: post.Author},
+			Id:          guid(post),
+			Created:     post.Date,
+		}, attachments)
+		err := f.save()
+		if err != nil {
+			log.WithFields(log.Fields{"destination": destination, "type": rss.entity.Type}).Error(err)
+		}
+	}
 }
 
-// Handler return RSS XML
+// Handler return feed in RSS, Atom or JSON format
 func (rss *RSS) Handler(w http.ResponseWriter, r *http.Request) {
-	if len(rss.feed.Items) > 0 {
-		xml, err := rss.feed.ToRss()
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(err.Error()))
-		} else {
-			w.Header().Set("Content-Type", "application/xml")
-			w.Write([]byte(xml))
-		}
-	} else {
+	rss.mutex.RLock()
+	defer rss.mutex.RUnlock()
+
+	format := feedFormat(r.URL.Path)
+	f, ok := rss.destinations[strings.TrimPrefix(r.URL.Path, "/"+format+"/")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	snapshot := *f
+	snapshotFeed := *f.Feed
+	snapshotFeed.Items = f.actualItems()
+	snapshot.Feed = &snapshotFeed
+	if len(snapshot.Items) == 0 {
 		w.Write([]byte("No new RSS"))
+		return
 	}
+
+	content, err := render(&snapshot, format, requestURL(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	sum := sha1.Sum(content)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+	modified := snapshot.Updated.UTC().Truncate(time.Second)
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		if strings.Contains(match, etag) || match == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	} else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !modified.After(since) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypes[format])
+	w.Write(content)
 }
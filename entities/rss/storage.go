@@ -0,0 +1,137 @@
+package rss
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gorilla/feeds"
+	"github.com/n0madic/crossposter"
+	"github.com/n0madic/crossposter/utils"
+)
+
+// feed of destination with limits and persistent storage
+type feed struct {
+	*feeds.Feed
+	media    map[string][]media
+	maxItems int
+	maxAge   time.Duration
+	file     string
+	podcast  bool
+	explicit string
+	category string
+}
+
+type storedFeed struct {
+	Updated time.Time          `json:"updated"`
+	Items   []*feeds.Item      `json:"items"`
+	Media   map[string][]media `json:"media,omitempty"`
+}
+
+// add item with attachments to feed replacing item with same GUID
+func (f *feed) add(item *feeds.Item, attachments []media) {
+	for i, existing := range f.Items {
+		if existing.Id == item.Id {
+			f.Items = append(f.Items[:i], f.Items[i+1:]...)
+			break
+		}
+	}
+	f.Items = append(f.Items, item)
+	if len(attachments) > 0 {
+		f.media[item.Id] = attachments
+	}
+	f.Updated = time.Now()
+	f.prune()
+}
+
+// prune items over size limit and expired items
+func (f *feed) prune() {
+	if f.maxItems > 0 && len(f.Items) > f.maxItems {
+		f.Items = f.Items[len(f.Items)-f.maxItems:]
+	}
+	if f.maxAge > 0 {
+		f.Items = f.actualItems()
+	}
+	actual := make(map[string][]media, len(f.media))
+	for _, item := range f.Items {
+		if m, ok := f.media[item.Id]; ok {
+			actual[item.Id] = m
+		}
+	}
+	f.media = actual
+}
+
+// actualItems return items not older than max age
+func (f *feed) actualItems() []*feeds.Item {
+	if f.maxAge == 0 {
+		return f.Items
+	}
+	deadline := time.Now().Add(-f.maxAge)
+	items := make([]*feeds.Item, 0, len(f.Items))
+	for _, item := range f.Items {
+		if item.Created.After(deadline) {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// load feed items from storage file
+func (f *feed) load() error {
+	if f.file == "" {
+		return nil
+	}
+	content, err := ioutil.ReadFile(f.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var stored storedFeed
+	err = json.Unmarshal(content, &stored)
+	if err != nil {
+		return err
+	}
+	f.Updated = stored.Updated
+	f.Items = stored.Items
+	if stored.Media != nil {
+		f.media = stored.Media
+	}
+	f.prune()
+	return nil
+}
+
+// save feed items to storage file
+func (f *feed) save() error {
+	if f.file == "" {
+		return nil
+	}
+	content, err := json.Marshal(storedFeed{Updated: f.Updated, Items: f.Items, Media: f.media})
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(f.file, content, 0644)
+}
+
+// storageFile return path of feed storage file for destination
+func storageFile(dir, destination string) string {
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, strings.ReplaceAll(destination, "/", "_")+".json")
+}
+
+// guid return stable identifier of post
+func guid(post crossposter.Post) string {
+	if post.URL != "" {
+		return post.URL
+	}
+	sum := sha1.Sum([]byte(post.Date.UTC().Format(time.RFC3339) + post.Title + post.Text))
+	return "urn:sha1:" + hex.EncodeToString(sum[:])
+}
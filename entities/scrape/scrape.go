@@ -0,0 +1,237 @@
+package scrape
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/n0madic/crossposter"
+	"github.com/n0madic/crossposter/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultPages = 1
+
+// Scrape entity
+type Scrape struct {
+	entity *crossposter.Entity
+}
+
+// selector of element text or attribute, in form "css selector@attribute"
+type selector struct {
+	css  string
+	attr string
+}
+
+func init() {
+	crossposter.AddEntity("scrape", New)
+}
+
+// New return scrape entity
+func New(entity crossposter.Entity) (crossposter.EntityInterface, error) {
+	for _, source := range entity.Sources {
+		if entity.Option("item", source) == "" {
+			return nil, fmt.Errorf("item selector not specified for %s", source)
+		}
+	}
+	return &Scrape{entity: &entity}, nil
+}
+
+// Get items from web pages
+func (scrape *Scrape) Get(lastUpdate time.Time) {
+	defer crossposter.WaitGroup.Done()
+	published, err := crossposter.NewPublished(10000, lastUpdate)
+	if err != nil {
+		log.WithFields(log.Fields{"type": scrape.entity.Type}).Error(err)
+		return
+	}
+
+	for {
+		for _, source := range scrape.entity.Sources {
+			scrapeLogger := log.WithFields(log.Fields{"source": source, "type": scrape.entity.Type})
+			scrapeLogger.Println("Check updates")
+
+			posts, err := scrape.scrapeSource(source)
+			if err != nil {
+				scrapeLogger.Error(err)
+				if len(posts) == 0 {
+					continue
+				}
+			}
+
+			sort.SliceStable(posts, func(i, j int) bool {
+				return posts[i].Date.Before(posts[j].Date)
+			})
+
+			// Without dates items found on first check are considered old
+			for _, post := range posts {
+				if published.IsNew(source, post.URL, post.Date) {
+					if post.Date.IsZero() {
+						post.Date = time.Now()
+					}
+					for _, topic := range scrape.entity.Topics {
+						crossposter.Events.Publish(topic, post)
+					}
+				}
+			}
+			published.Initialize(source)
+		}
+		time.Sleep(time.Duration(scrape.entity.Wait) * time.Minute)
+	}
+}
+
+// Post not implemented
+func (scrape *Scrape) Post(post crossposter.Post) {}
+
+// Handler not implemented
+func (scrape *Scrape) Handler(w http.ResponseWriter, r *http.Request) {}
+
+// scrapeSource collect posts from source pages
+func (scrape *Scrape) scrapeSource(source string) ([]crossposter.Post, error) {
+	option := func(name string) string {
+		return scrape.entity.Option(name, source)
+	}
+
+	charset := option("charset")
+	if charset == "" {
+		charset = "utf-8"
+	}
+	pages := defaultPages
+	if option("pages") != "" {
+		n, err := strconv.Atoi(option("pages"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pages: %v", err)
+		}
+		pages = n
+	}
+
+	pageURL := source
+	if option("url") != "" {
+		pageURL = option("url")
+	}
+
+	var posts []crossposter.Post
+	visited := make(map[string]bool)
+	for page := 0; page < pages && pageURL != "" && !visited[pageURL]; page++ {
+		visited[pageURL] = true
+		base, err := url.Parse(pageURL)
+		if err != nil {
+			return posts, err
+		}
+		doc, err := utils.NewDocumentToUTF8(pageURL, charset)
+		if err != nil {
+			return posts, err
+		}
+
+		doc.Find(option("item")).Each(func(i int, sel *goquery.Selection) {
+			post, ok := parseItem(sel, base, option)
+			if ok {
+				posts = append(posts, post)
+			}
+		})
+
+		pageURL = ""
+		if option("next") != "" {
+			if next := extract(doc.Selection, parseSelector(option("next"), "href")); next != "" {
+				pageURL = resolve(base, next)
+			}
+		}
+	}
+	return posts, nil
+}
+
+// parseItem build post from item element
+func parseItem(sel *goquery.Selection, base *url.URL, option func(string) string) (crossposter.Post, bool) {
+	post := crossposter.Post{
+		Title:  extract(sel, parseSelector(option("title"), "")),
+		Author: extract(sel, parseSelector(option("author"), "")),
+	}
+
+	link := extract(sel, parseSelector(option("link"), "href"))
+	if link == "" {
+		return post, false
+	}
+	post.URL = resolve(base, link)
+
+	// Items without date are considered old on first check
+	if option("date") != "" {
+		value := extract(sel, parseSelector(option("date"), ""))
+		layout := option("date_layout")
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		date, err := time.Parse(layout, value)
+		if err != nil {
+			log.WithFields(log.Fields{"link": post.URL, "layout": layout}).Warnf("Can't parse date of item: %s", err)
+		} else {
+			post.Date = date
+		}
+	}
+
+	if option("images") != "" {
+		images := parseSelector(option("images"), "src")
+		sel.Find(images.css).Each(func(i int, img *goquery.Selection) {
+			if src := strings.TrimSpace(img.AttrOr(images.attr, "")); src != "" {
+				post.Attachments = append(post.Attachments, resolve(base, src))
+			}
+		})
+	}
+
+	if option("body") != "" {
+		body := sel.Find(option("body")).First()
+		if option("images") != "" {
+			body.Find(parseSelector(option("images"), "src").css).Remove()
+		}
+		for _, attr := range []string{"href", "src"} {
+			body.Find("[" + attr + "]").Each(func(i int, s *goquery.Selection) {
+				s.SetAttr(attr, resolve(base, s.AttrOr(attr, "")))
+			})
+		}
+		html, err := body.Html()
+		if err != nil {
+			html = body.Text()
+		}
+		post.Text = strings.TrimSpace(html)
+	}
+
+	return post, post.Title != "" || post.Text != ""
+}
+
+// parseSelector split selector to CSS and attribute parts
+func parseSelector(value, defaultAttr string) selector {
+	s := selector{css: value, attr: defaultAttr}
+	if i := strings.LastIndex(value, "@"); i != -1 {
+		s.css = strings.TrimSpace(value[:i])
+		s.attr = strings.TrimSpace(value[i+1:])
+	}
+	return s
+}
+
+// extract text or attribute of first element matched selector
+func extract(sel *goquery.Selection, s selector) string {
+	if s.css == "" && s.attr == "" {
+		return ""
+	}
+	found := sel
+	if s.css != "" {
+		found = sel.Find(s.css).First()
+	}
+	if s.attr != "" {
+		return strings.TrimSpace(found.AttrOr(s.attr, ""))
+	}
+	return strings.TrimSpace(found.Text())
+}
+
+// resolve URL relative to page
+func resolve(base *url.URL, ref string) string {
+	u, err := base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
@@ -0,0 +1,39 @@
+package telegram
+
+import (
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// resolveChat return chat for destination by ID or username with caching
+func (tg *Telegram) resolveChat(destination string) (tgbotapi.Chat, error) {
+	tg.chatsMutex.Lock()
+	defer tg.chatsMutex.Unlock()
+
+	if chat, ok := tg.chats[destination]; ok {
+		return chat, nil
+	}
+
+	config := tgbotapi.ChatConfig{}
+	if id, err := strconv.ParseInt(destination, 10, 64); err == nil {
+		config.ChatID = id
+	} else {
+		config.SuperGroupUsername = "@" + strings.TrimPrefix(destination, "@")
+	}
+	chat, err := tg.client.GetChat(config)
+	if err != nil {
+		return chat, err
+	}
+	tg.chats[destination] = chat
+	return chat, nil
+}
+
+// chatLink return public link to message in chat
+func chatLink(chat tgbotapi.Chat, messageID int) string {
+	if chat.UserName != "" {
+		return "https://t.me/" + chat.UserName + "/" + strconv.Itoa(messageID)
+	}
+	return "https://t.me/c/" + strings.TrimPrefix(strconv.FormatInt(chat.ID, 10), "-100") + "/" + strconv.Itoa(messageID)
+}
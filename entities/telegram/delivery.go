@@ -0,0 +1,80 @@
+package telegram
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/n0madic/crossposter"
+	log "github.com/sirupsen/logrus"
+)
+
+// delivery of post to destination with send options
+type delivery struct {
+	chat   tgbotapi.Chat
+	params url.Values
+	markup string
+	pin    bool
+	posted bool
+	logger *log.Entry
+}
+
+// newDelivery return delivery with options of destination
+func (tg *Telegram) newDelivery(chat tgbotapi.Chat, destination string, post crossposter.Post, logger *log.Entry) *delivery {
+	d := &delivery{chat: chat, params: url.Values{}, logger: logger}
+	d.params.Set("chat_id", strconv.FormatInt(chat.ID, 10))
+	for _, name := range []string{"disable_notification", "protect_content"} {
+		if enabled, _ := strconv.ParseBool(tg.entity.Option(name, destination)); enabled {
+			d.params.Set(name, "true")
+		}
+	}
+	if threadID := tg.entity.Option("thread_id", destination); threadID != "" {
+		d.params.Set("message_thread_id", threadID)
+	}
+	d.pin, _ = strconv.ParseBool(tg.entity.Option("pin", destination))
+	if button := tg.entity.Option("button", destination); button != "" && post.URL != "" {
+		markup := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonURL(button, post.URL)),
+		)
+		data, err := json.Marshal(markup)
+		if err == nil {
+			d.markup = string(data)
+		}
+	}
+	return d
+}
+
+// values return copy of request parameters with inline keyboard for first supported message
+func (d *delivery) values(withMarkup bool) url.Values {
+	params := url.Values{}
+	for key, value := range d.params {
+		params[key] = append([]string{}, value...)
+	}
+	if withMarkup && d.markup != "" {
+		params.Set("reply_markup", d.markup)
+		d.markup = ""
+	}
+	return params
+}
+
+// delivered log sent message and pin first message of post
+func (tg *Telegram) delivered(d *delivery, messageID int) {
+	d.logger.Printf("Posted %s", chatLink(d.chat, messageID))
+	if !d.pin || d.posted {
+		d.posted = true
+		return
+	}
+	d.posted = true
+
+	params := url.Values{}
+	params.Set("chat_id", strconv.FormatInt(d.chat.ID, 10))
+	params.Set("message_id", strconv.Itoa(messageID))
+	if d.params.Get("disable_notification") != "" {
+		params.Set("disable_notification", d.params.Get("disable_notification"))
+	}
+	_, err := tg.client.MakeRequest("pinChatMessage", params)
+	if err != nil {
+		d.logger.Warnf("Can't pin message: %s", err)
+	}
+}
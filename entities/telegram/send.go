@@ -0,0 +1,282 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/n0madic/crossposter"
+	"github.com/n0madic/crossposter/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	maxAlbumSize     = 10
+	maxCaptionLength = 1024
+)
+
+// httpClient for downloading of media
+var httpClient = &http.Client{Timeout: time.Minute}
+
+// attachment of post with kind of Telegram media
+type attachment struct {
+	url  string
+	kind string
+}
+
+// upload is file for sending in multipart request
+type upload struct {
+	field  string
+	source string
+}
+
+// postAttachments return remote attachments of post with media kinds detected by content
+func postAttachments(post crossposter.Post) []attachment {
+	attachments := make([]attachment, 0, len(post.Attachments))
+	for _, attach := range post.Attachments {
+		if !isRemote(attach) {
+			log.WithFields(log.Fields{"attachment": attach}).Warn("Skip attachment without HTTP URL")
+			continue
+		}
+		attachments = append(attachments, attachment{url: attach, kind: attachmentKind(attach)})
+	}
+	return attachments
+}
+
+// attachmentKind detect media kind by extension or content type of URL
+func attachmentKind(rawurl string) string {
+	mediaType := utils.MediaType(rawurl)
+	switch {
+	case mediaType == "":
+		return "photo"
+	case mediaType == "image/gif":
+		return "animation"
+	case strings.HasPrefix(mediaType, "image/"):
+		return "photo"
+	case mediaType == "video/mp4" || mediaType == "video/quicktime":
+		return "video"
+	case mediaType == "audio/mpeg" || mediaType == "audio/mp4" || mediaType == "audio/x-m4a":
+		return "audio"
+	}
+	return "document"
+}
+
+// albumKind return kind of album for media or empty string if media can't be grouped
+func albumKind(kind string) string {
+	switch kind {
+	case "photo", "video":
+		return "visual"
+	case "audio", "document":
+		return kind
+	}
+	return ""
+}
+
+// groupAttachments split attachments to albums with compatible media
+func groupAttachments(attachments []attachment) [][]attachment {
+	var groups [][]attachment
+	for _, attach := range attachments {
+		last := len(groups) - 1
+		if last >= 0 && albumKind(attach.kind) != "" &&
+			albumKind(groups[last][0].kind) == albumKind(attach.kind) &&
+			len(groups[last]) < maxAlbumSize {
+			groups[last] = append(groups[last], attach)
+		} else {
+			groups = append(groups, []attachment{attach})
+		}
+	}
+	return groups
+}
+
+// sendText send text message
+func (tg *Telegram) sendText(d *delivery, text string, disablePreview bool) (tgbotapi.Message, error) {
+	params := d.values(true)
+	params.Set("text", text)
+	params.Set("parse_mode", "HTML")
+	if disablePreview {
+		params.Set("disable_web_page_preview", "true")
+	}
+
+	var msg tgbotapi.Message
+	resp, err := tg.client.MakeRequest("sendMessage", params)
+	if err != nil {
+		return msg, err
+	}
+	err = json.Unmarshal(resp.Result, &msg)
+	return msg, err
+}
+
+// sendMedia send single attachment with caption
+func (tg *Telegram) sendMedia(d *delivery, attach attachment, caption string) (tgbotapi.Message, error) {
+	params := d.values(true)
+	if caption != "" {
+		params.Set("caption", caption)
+		params.Set("parse_mode", "HTML")
+	}
+	method := "send" + strings.Title(attach.kind)
+
+	var msg tgbotapi.Message
+	result, err := tg.sendWithFallback(method, params, attach.kind, []attachment{attach}, func(media []string) {
+		params.Set(attach.kind, media[0])
+	})
+	if err != nil {
+		return msg, err
+	}
+	err = json.Unmarshal(result, &msg)
+	return msg, err
+}
+
+// sendAlbum send attachments as media group with caption on first item
+func (tg *Telegram) sendAlbum(d *delivery, attachments []attachment, caption string) ([]tgbotapi.Message, error) {
+	params := d.values(false)
+
+	var msgs []tgbotapi.Message
+	result, err := tg.sendWithFallback("sendMediaGroup", params, "", attachments, func(media []string) {
+		group := make([]map[string]string, 0, len(attachments))
+		for i, attach := range attachments {
+			item := map[string]string{"type": attach.kind, "media": media[i]}
+			if i == 0 && caption != "" {
+				item["caption"] = caption
+				item["parse_mode"] = "HTML"
+			}
+			group = append(group, item)
+		}
+		data, _ := json.Marshal(group)
+		params.Set("media", string(data))
+	})
+	if err != nil {
+		return msgs, err
+	}
+	err = json.Unmarshal(result, &msgs)
+	return msgs, err
+}
+
+// sendWithFallback send media by URL and upload files when Telegram can't fetch them
+func (tg *Telegram) sendWithFallback(method string, params url.Values, field string, attachments []attachment, setMedia func([]string)) (json.RawMessage, error) {
+	media := make([]string, len(attachments))
+	for i, attach := range attachments {
+		media[i] = attach.url
+	}
+	setMedia(media)
+	resp, err := tg.client.MakeRequest(method, params)
+	if err == nil || !isFetchError(err) {
+		return resp.Result, err
+	}
+
+	var uploads []upload
+	for i, attach := range attachments {
+		name := field
+		if name == "" {
+			name = fmt.Sprintf("file%d", i)
+			media[i] = "attach://" + name
+		}
+		uploads = append(uploads, upload{field: name, source: attach.url})
+	}
+	setMedia(media)
+	if field != "" {
+		params.Del(field)
+	}
+	return tg.uploadFiles(method, params, uploads)
+}
+
+// uploadFiles send multipart request with files downloaded from URLs
+func (tg *Telegram) uploadFiles(method string, params url.Values, uploads []upload) (json.RawMessage, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for key := range params {
+		err := writer.WriteField(key, params.Get(key))
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, file := range uploads {
+		reader, err := openSource(file.source)
+		if err != nil {
+			return nil, err
+		}
+		part, err := writer.CreateFormFile(file.field, fileName(file.source))
+		if err == nil {
+			_, err = io.Copy(part, reader)
+		}
+		reader.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := tg.client.Client.Post(fmt.Sprintf(tgbotapi.APIEndpoint, tg.client.Token, method), writer.FormDataContentType(), body)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var apiResp tgbotapi.APIResponse
+	err = json.NewDecoder(res.Body).Decode(&apiResp)
+	if err != nil {
+		return nil, err
+	}
+	if !apiResp.Ok {
+		return nil, errors.New(apiResp.Description)
+	}
+	return apiResp.Result, nil
+}
+
+// openSource download remote URL, local files are never read
+func openSource(source string) (io.ReadCloser, error) {
+	if !isRemote(source) {
+		return nil, fmt.Errorf("can't upload %s: only HTTP URLs are allowed", source)
+	}
+	res, err := httpClient.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("can't download %s: %s", source, res.Status)
+	}
+	return res.Body, nil
+}
+
+// fileName return name of file from URL or path
+func fileName(source string) string {
+	if u, err := url.Parse(source); err == nil && u.Path != "" {
+		if name := path.Base(u.Path); name != "/" && name != "." {
+			return name
+		}
+	}
+	return "file"
+}
+
+// isRemote check that source is HTTP URL
+func isRemote(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// isFetchError check that Telegram failed to get file by URL
+func isFetchError(err error) bool {
+	text := err.Error()
+	for _, marker := range []string{
+		"failed to get HTTP URL content",
+		"wrong file identifier/HTTP URL specified",
+		"wrong type of the web page content",
+		"WEBPAGE_",
+	} {
+		if strings.Contains(text, marker) {
+			return true
+		}
+	}
+	return false
+}
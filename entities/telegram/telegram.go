@@ -2,13 +2,12 @@ package telegram
 
 import (
 	"fmt"
+	"html"
 	"net/http"
-	"strconv"
-	"strings"
+	"sync"
 	"time"
 	"unicode/utf8"
 
-	"github.com/StarkBotsIndustries/telegraph/v2"
 	"github.com/davecgh/go-spew/spew"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 	"github.com/n0madic/crossposter"
@@ -18,9 +17,20 @@ import (
 
 // Telegram entity
 type Telegram struct {
-	entity         *crossposter.Entity
-	client         *tgbotapi.BotAPI
-	telegraphToken string
+	entity     *crossposter.Entity
+	client     *tgbotapi.BotAPI
+	chats      map[string]tgbotapi.Chat
+	chatsMutex sync.Mutex
+	updates    chan update
+
+	telegraphToken     string
+	telegraphAuthor    string
+	telegraphAuthorURL string
+	telegraphThreshold int
+	telegraphAlways    bool
+	telegraphFile      string
+	telegraphPages     map[string]string
+	telegraphMutex     sync.Mutex
 }
 
 func init() {
@@ -33,193 +43,157 @@ func New(entity crossposter.Entity) (crossposter.EntityInterface, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to login: %v", err)
 	}
-	acc, err := telegraph.CreateAccount(
-		telegraph.CreateAccountOpts{ShortName: "crossposter"},
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get Telegraph token: %v", err)
+	tg := &Telegram{
+		entity:  &entity,
+		client:  client,
+		chats:   make(map[string]tgbotapi.Chat),
+		updates: make(chan update, 100),
 	}
-	return &Telegram{
-		entity:         &entity,
-		client:         client,
-		telegraphToken: acc.AccessToken,
-	}, nil
+	if len(entity.Destinations) > 0 {
+		err = tg.setupTelegraph()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if name := entity.Options["webhook"]; name != "" {
+		if entity.Options["secret"] == "" {
+			return nil, fmt.Errorf("telegram webhook requires secret")
+		}
+		http.HandleFunc("/telegram/"+name, tg.Handler)
+	}
+	if entity.Options["secret"] != "" && entity.Options["webhook_url"] == "" {
+		return nil, fmt.Errorf("telegram webhook secret requires webhook_url to register it")
+	}
+	for _, destination := range entity.Destinations {
+		chat, err := tg.resolveChat(destination)
+		if err != nil {
+			log.WithFields(log.Fields{"channel": destination, "type": entity.Type}).Warnf("Can't resolve chat: %s", err)
+		} else {
+			log.WithFields(log.Fields{"channel": destination, "type": entity.Type}).Debugf("Resolved chat ID %d", chat.ID)
+		}
+	}
+	return tg, nil
 }
 
 // Get message from Telegram channel
 func (tg *Telegram) Get(lastUpdate time.Time) {
 	defer crossposter.WaitGroup.Done()
 
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
-
 	tgLogger := log.WithFields(log.Fields{"sources": tg.entity.Sources, "type": tg.entity.Type})
 
-	updates, err := tg.client.GetUpdatesChan(u)
-	if err != nil {
-		tgLogger.Panicln(err)
-	}
-
-	tgLogger.Println("Check updates")
-	for update := range updates {
-		if update.ChannelPost == nil {
-			continue
-		}
-
-		if utils.StringInSlice(update.ChannelPost.Chat.UserName, tg.entity.Sources) ||
-			utils.StringInSlice(strconv.FormatInt(update.ChannelPost.Chat.ID, 10), tg.entity.Sources) {
-			mediaURLs := []string{}
-			if update.ChannelPost.Photo != nil {
-				pixels := 0
-				idx := -1
-				for i, p := range *update.ChannelPost.Photo { // find largest image in set
-					pix := p.Height * p.Width
-					if pix > pixels {
-						pixels = pix
-						idx = i
-					}
-				}
-				if idx != -1 {
-					url, err := tg.client.GetFileDirectURL((*update.ChannelPost.Photo)[idx].FileID)
-					if err != nil {
-						log.Panicln(err)
-					}
-					mediaURLs = append(mediaURLs, url)
-				}
-			}
-
-			url := ""
-			if update.ChannelPost.Chat.UserName != "" {
-				url = fmt.Sprintf("https://t.me/%s/%v", update.ChannelPost.Chat.UserName, update.ChannelPost.MessageID)
-			}
-
-			username := ""
-			if update.ChannelPost.From != nil {
-				username = update.ChannelPost.From.UserName
-			}
-
-			post := crossposter.Post{
-				Date:        time.Unix(int64(update.ChannelPost.Date), 0),
-				URL:         url,
-				Title:       update.ChannelPost.Caption,
-				Author:      username,
-				Text:        update.ChannelPost.Text,
-				Attachments: mediaURLs,
-			}
-			for _, topic := range tg.entity.Topics {
-				crossposter.Events.Publish(topic, post)
-			}
+	if tg.entity.Options["webhook"] != "" {
+		err := tg.setWebhook()
+		if err != nil {
+			tgLogger.Errorf("Can't set webhook: %s", err)
 		}
+		tgLogger.Println("Wait updates on webhook")
+	} else {
+		tgLogger.Println("Check updates")
+		go tg.poll(tg.updates)
 	}
+	tg.process(tg.updates)
 }
 
 // Post message to Telegram channel
 func (tg *Telegram) Post(post crossposter.Post) {
 	for _, destination := range tg.entity.Destinations {
-		channelID, errID := strconv.ParseInt(destination, 10, 64)
-
 		tgLogger := log.WithFields(log.Fields{"channel": destination, "type": tg.entity.Type})
 
-		err := post.ExtractImages()
+		chat, err := tg.resolveChat(destination)
+		if err != nil {
+			tgLogger.Errorf("Can't resolve chat: %s", err)
+			continue
+		}
+		d := tg.newDelivery(chat, destination, post, tgLogger)
+
+		if tg.telegraphAlways && isLongForm(post) {
+			tg.postArticle(d, post)
+			continue
+		}
+
+		err = post.ExtractImages()
 		if err != nil {
 			tgLogger.Warnf("Can't extract image: %s", err)
 		}
 
 		text := sanitize(post.FullText())
 
-		if (text != "" && len(post.Attachments) == 0) || utf8.RuneCountInString(text) > 1024 {
+		if (text != "" && len(post.Attachments) == 0) || utf8.RuneCountInString(text) > maxCaptionLength {
 			disablePreview := false
-			if utf8.RuneCountInString(text) > 4096 {
-				page, err := telegraph.CreatePage(telegraph.CreatePageOpts{
-					Title:       post.Title,
-					AuthorName:  post.Author,
-					HTMLContent: text,
-					AccessToken: tg.telegraphToken,
-				})
-				if err == nil {
-					tgLogger.Printf("Telegraph page created: %v", page.URL)
-					text = fmt.Sprintf("<a href=\"%s\">%s</a>", page.URL, page.Title)
-				} else {
+			if utf8.RuneCountInString(text) > tg.telegraphThreshold {
+				pageURL, err := tg.telegraphPage(post, text, false)
+				if pageURL != "" {
+					tgLogger.Printf("Telegraph page: %v", pageURL)
+					text = fmt.Sprintf("<a href=\"%s\">%s</a>", pageURL, html.EscapeString(pageTitle(post, text)))
+				}
+				if err != nil {
 					tgLogger.Warn("Can't create Telegraph page: ", err)
-					text = utils.TruncateText(text, 4095) + "…"
+				}
+				if pageURL == "" && utf8.RuneCountInString(text) > maxMessageLength {
+					text = utils.TruncateText(text, maxMessageLength-1) + "…"
 				}
 			} else if len(post.Attachments) > 0 && sanitize(post.Text) != "" {
 				disablePreview = true
 			}
 
-			var msg tgbotapi.MessageConfig
-			if errID == nil {
-				msg = tgbotapi.NewMessage(channelID, text)
-			} else {
-				if !strings.HasPrefix(destination, "@") {
-					destination = "@" + destination
-				}
-				msg = tgbotapi.NewMessageToChannel(destination, text)
-			}
-			msg.ParseMode = "HTML"
-			msg.DisableWebPagePreview = disablePreview
-
-			pmsg, err := tg.client.Send(msg)
+			pmsg, err := tg.sendText(d, text, disablePreview)
 			if err != nil {
 				tgLogger.Error(err)
-				spew.Dump(msg)
+				spew.Dump(text)
 			} else {
-				tgLogger.Printf("Posted https://t.me/%s/%v", pmsg.Chat.Title, pmsg.MessageID)
+				tg.delivered(d, pmsg.MessageID)
 			}
 		}
 
 		if len(post.Attachments) > 0 {
-			if errID != nil {
-				tgLogger.Error("Need ChatID for post attachments")
-			} else {
-				var msg tgbotapi.Chattable
-				if len(post.Attachments) == 1 {
-					lowerURL := strings.ToLower(post.Attachments[0])
-					if strings.HasSuffix(lowerURL, "gif") || strings.HasSuffix(lowerURL, "pdf") {
-						doc := tgbotapi.NewDocumentShare(channelID, post.Attachments[0])
-						if utf8.RuneCountInString(text) <= 1024 {
-							doc.Caption = text
-							doc.ParseMode = "HTML"
-						}
-						msg = doc
+			caption := ""
+			if utf8.RuneCountInString(text) <= maxCaptionLength {
+				caption = text
+			}
+			for _, group := range groupAttachments(postAttachments(post)) {
+				if len(group) == 1 {
+					pmsg, err := tg.sendMedia(d, group[0], caption)
+					if err != nil {
+						tgLogger.Errorf("Can't send %s %s: %s", group[0].kind, group[0].url, err)
 					} else {
-						photo := tgbotapi.NewPhotoShare(channelID, post.Attachments[0])
-						if utf8.RuneCountInString(text) <= 1024 {
-							photo.Caption = text
-							photo.ParseMode = "HTML"
-						}
-						msg = photo
+						tg.delivered(d, pmsg.MessageID)
 					}
 				} else {
-					var files []interface{}
-					for i := 0; i < 10 && i < len(post.Attachments); i++ {
-						files = append(files, tgbotapi.NewInputMediaPhoto(post.Attachments[i]))
-					}
-					if utf8.RuneCountInString(text) <= 1024 {
-						files[0] = tgbotapi.InputMediaPhoto{
-							Type:      "photo",
-							Media:     post.Attachments[0],
-							Caption:   text,
-							ParseMode: "HTML",
-						}
-					}
-					msg = tgbotapi.NewMediaGroup(channelID, files)
-				}
-				pmsg, err := tg.client.Send(msg)
-				if err != nil {
-					tgLogger.Error(err)
-					spew.Dump(msg)
-				} else {
-					if pmsg.Chat != nil {
-						tgLogger.Printf("Posted https://t.me/%s/%v", pmsg.Chat.Title, pmsg.MessageID)
-					} else {
-						tgLogger.Printf("Posted media group")
+					pmsgs, err := tg.sendAlbum(d, group, caption)
+					if err != nil {
+						tgLogger.Errorf("Can't send media group: %s", err)
+						spew.Dump(group)
+					} else if len(pmsgs) > 0 {
+						tg.delivered(d, pmsgs[0].MessageID)
 					}
 				}
+				caption = ""
 			}
 		}
 	}
 }
 
-// Handler not implemented
-func (tg *Telegram) Handler(w http.ResponseWriter, r *http.Request) {}
+// postArticle publish post as Telegraph page with Instant View link
+func (tg *Telegram) postArticle(d *delivery, post crossposter.Post) {
+	pageURL, err := tg.telegraphPage(post, post.Text, true)
+	if pageURL == "" {
+		d.logger.Error("Can't create Telegraph page: ", err)
+		return
+	}
+	if err != nil {
+		d.logger.Warn(err)
+	}
+	d.logger.Printf("Telegraph page: %v", pageURL)
+
+	text := fmt.Sprintf("<b><a href=\"%s\">%s</a></b>", pageURL, html.EscapeString(post.Title))
+	if post.URL != "" && d.markup == "" {
+		text += fmt.Sprintf("\n<a href=\"%s\">%s</a>", post.URL, html.EscapeString(post.URL))
+	}
+
+	pmsg, err := tg.sendText(d, text, false)
+	if err != nil {
+		d.logger.Error(err)
+	} else {
+		tg.delivered(d, pmsg.MessageID)
+	}
+}
@@ -0,0 +1,188 @@
+package telegram
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/StarkBotsIndustries/telegraph/v2"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/n0madic/crossposter"
+	"github.com/n0madic/crossposter/utils"
+)
+
+const (
+	maxMessageLength = 4096
+	telegraphURL     = "https://telegra.ph"
+)
+
+// setupTelegraph configure Telegraph account and load stored pages
+func (tg *Telegram) setupTelegraph() error {
+	tg.telegraphToken = tg.entity.Options["telegraph_token"]
+	tg.telegraphAuthor = tg.entity.Options["telegraph_author"]
+	tg.telegraphAuthorURL = tg.entity.Options["telegraph_author_url"]
+	tg.telegraphAlways, _ = strconv.ParseBool(tg.entity.Options["telegraph_always"])
+	tg.telegraphFile = tg.entity.Options["telegraph_storage"]
+	tg.telegraphPages = make(map[string]string)
+
+	// Telegram doesn't accept messages longer than limit anyway
+	tg.telegraphThreshold = maxMessageLength
+	if threshold := tg.entity.Options["telegraph_threshold"]; threshold != "" {
+		n, err := strconv.Atoi(threshold)
+		if err != nil {
+			return fmt.Errorf("invalid telegraph_threshold: %v", err)
+		}
+		if n < maxMessageLength {
+			tg.telegraphThreshold = n
+		}
+	}
+
+	if tg.telegraphToken == "" {
+		acc, err := telegraph.CreateAccount(telegraph.CreateAccountOpts{
+			ShortName:  "crossposter",
+			AuthorName: tg.telegraphAuthor,
+			AuthorURL:  tg.telegraphAuthorURL,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get Telegraph token: %v", err)
+		}
+		tg.telegraphToken = acc.AccessToken
+	}
+
+	if tg.telegraphFile != "" {
+		content, err := ioutil.ReadFile(tg.telegraphFile)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err == nil {
+			err = json.Unmarshal(content, &tg.telegraphPages)
+			if err != nil {
+				return fmt.Errorf("can't load Telegraph pages: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// telegraphPage return URL of existing or new Telegraph page for post
+func (tg *Telegram) telegraphPage(post crossposter.Post, content string, uploadImages bool) (string, error) {
+	tg.telegraphMutex.Lock()
+	defer tg.telegraphMutex.Unlock()
+
+	key := post.URL
+	if key == "" {
+		sum := sha1.Sum([]byte(post.Title + content))
+		key = "sha1:" + hex.EncodeToString(sum[:])
+	}
+	// Full article pages with images differ from pages of long text
+	if uploadImages {
+		key = "article:" + key
+	}
+	if pageURL, ok := tg.telegraphPages[key]; ok {
+		return pageURL, nil
+	}
+
+	if uploadImages {
+		content = uploadTelegraphImages(content)
+	}
+
+	title := pageTitle(post, content)
+	author := tg.telegraphAuthor
+	if author == "" {
+		author = post.Author
+	}
+	authorURL := tg.telegraphAuthorURL
+	if authorURL == "" {
+		authorURL = post.URL
+	}
+
+	page, err := telegraph.CreatePage(telegraph.CreatePageOpts{
+		Title:       title,
+		AuthorName:  author,
+		AuthorURL:   authorURL,
+		HTMLContent: telegraphSanitize(content),
+		AccessToken: tg.telegraphToken,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	tg.telegraphPages[key] = page.URL
+	if tg.telegraphFile != "" {
+		data, err := json.Marshal(tg.telegraphPages)
+		if err == nil {
+			err = utils.WriteFileAtomic(tg.telegraphFile, data, 0644)
+		}
+		if err != nil {
+			return page.URL, fmt.Errorf("can't save Telegraph pages: %v", err)
+		}
+	}
+	return page.URL, nil
+}
+
+// isLongForm check that post is article suitable for Instant View
+func isLongForm(post crossposter.Post) bool {
+	return post.Title != "" && (strings.Contains(post.Text, "<img") ||
+		utf8.RuneCountInString(sanitize(post.Text)) > maxCaptionLength)
+}
+
+// uploadTelegraphImages upload images of HTML to Telegraph and replace their sources
+func uploadTelegraphImages(content string) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return content
+	}
+	doc.Find("img").Each(func(i int, sel *goquery.Selection) {
+		src, ok := sel.Attr("src")
+		if !ok || !isRemote(src) {
+			return
+		}
+		res, err := httpClient.Get(src)
+		if err != nil {
+			return
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return
+		}
+		path, err := telegraph.Upload(res.Body, "photo")
+		if err == nil && path != "" {
+			sel.SetAttr("src", telegraphURL+path)
+		}
+	})
+	result, err := doc.Find("body").Html()
+	if err != nil {
+		return content
+	}
+	return result
+}
+
+// telegraphSanitize HTML to tags supported by Telegraph
+func telegraphSanitize(content string) string {
+	p := bluemonday.NewPolicy()
+	p.AllowStandardURLs()
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowAttrs("src").OnElements("img", "video", "iframe")
+	p.AllowElements(
+		"aside", "b", "blockquote", "br", "code", "em", "figcaption", "figure",
+		"h3", "h4", "hr", "i", "li", "ol", "p", "pre", "s", "strong", "u", "ul",
+	)
+	return strings.TrimSpace(p.Sanitize(content))
+}
+
+// pageTitle return title of post or beginning of text
+func pageTitle(post crossposter.Post, content string) string {
+	if post.Title != "" {
+		return post.Title
+	}
+	return utils.TruncateText(html.UnescapeString(bluemonday.StrictPolicy().Sanitize(content)), 100)
+}
@@ -0,0 +1,213 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/n0madic/crossposter"
+	"github.com/n0madic/crossposter/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultAlbumWindow = 2 * time.Second
+
+// message extends library type with fields of newer Bot API
+type message struct {
+	tgbotapi.Message
+	MediaGroupID    string                   `json:"media_group_id"`
+	CaptionEntities []tgbotapi.MessageEntity `json:"caption_entities"`
+	AuthorSignature string                   `json:"author_signature"`
+}
+
+// update of channel posts
+type update struct {
+	UpdateID          int      `json:"update_id"`
+	ChannelPost       *message `json:"channel_post"`
+	EditedChannelPost *message `json:"edited_channel_post"`
+}
+
+// poll updates from Telegram with long polling
+func (tg *Telegram) poll(updates chan<- update) {
+	err := tg.deleteWebhook()
+	if err != nil {
+		log.WithFields(log.Fields{"type": tg.entity.Type}).Errorf("Can't delete webhook: %s", err)
+	}
+
+	offset := 0
+	for {
+		params := url.Values{}
+		params.Set("offset", strconv.Itoa(offset))
+		params.Set("timeout", "60")
+		params.Set("allowed_updates", `["channel_post","edited_channel_post"]`)
+
+		resp, err := tg.client.MakeRequest("getUpdates", params)
+		if err != nil {
+			log.WithFields(log.Fields{"type": tg.entity.Type}).Error(err)
+			time.Sleep(3 * time.Second)
+			continue
+		}
+		var batch []update
+		err = json.Unmarshal(resp.Result, &batch)
+		if err != nil {
+			log.WithFields(log.Fields{"type": tg.entity.Type}).Error(err)
+			continue
+		}
+		for _, u := range batch {
+			if u.UpdateID >= offset {
+				offset = u.UpdateID + 1
+			}
+			updates <- u
+		}
+	}
+}
+
+// process updates with reassembling of albums
+func (tg *Telegram) process(updates <-chan update) {
+	edited := strings.EqualFold(tg.entity.Options["edited"], "true")
+	window := defaultAlbumWindow
+	if w, err := time.ParseDuration(tg.entity.Options["album_window"]); err == nil {
+		window = w
+	}
+
+	albums := make(map[string][]*message)
+	flush := make(chan string)
+	for {
+		select {
+		case u := <-updates:
+			msg, isEdit := u.ChannelPost, false
+			if msg == nil && edited {
+				msg, isEdit = u.EditedChannelPost, true
+			}
+			if msg == nil || !tg.isSource(msg.Chat) {
+				continue
+			}
+			if msg.MediaGroupID == "" {
+				tg.publish([]*message{msg}, isEdit)
+				continue
+			}
+			key := msg.MediaGroupID
+			if isEdit {
+				key = "edited:" + key
+			}
+			if _, ok := albums[key]; !ok {
+				time.AfterFunc(window, func() { flush <- key })
+			}
+			albums[key] = append(albums[key], msg)
+		case key := <-flush:
+			tg.publish(albums[key], strings.HasPrefix(key, "edited:"))
+			delete(albums, key)
+		}
+	}
+}
+
+// isSource check chat in sources by username or ID
+func (tg *Telegram) isSource(chat *tgbotapi.Chat) bool {
+	if chat == nil {
+		return false
+	}
+	return utils.StringInSlice(chat.UserName, tg.entity.Sources) ||
+		utils.StringInSlice("@"+chat.UserName, tg.entity.Sources) ||
+		utils.StringInSlice(strconv.FormatInt(chat.ID, 10), tg.entity.Sources)
+}
+
+// publish messages of album as one post
+func (tg *Telegram) publish(messages []*message, edited bool) {
+	if len(messages) == 0 {
+		return
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].MessageID < messages[j].MessageID
+	})
+	first := messages[0]
+	tgLogger := log.WithFields(log.Fields{"source": first.Chat.UserName, "type": tg.entity.Type})
+
+	var texts, mediaURLs, mediaTypes []string
+	for _, msg := range messages {
+		if msg.Text != "" {
+			texts = append(texts, entitiesToHTML(msg.Text, msg.Entities))
+		}
+		if msg.Caption != "" {
+			texts = append(texts, entitiesToHTML(msg.Caption, &msg.CaptionEntities))
+		}
+		kind, fileID := messageMedia(msg)
+		if fileID == "" {
+			continue
+		}
+		fileURL, err := tg.client.GetFileDirectURL(fileID)
+		if err != nil {
+			tgLogger.Warnf("Can't get %s file: %s", kind, err)
+			continue
+		}
+		mediaURLs = append(mediaURLs, fileURL)
+		mediaTypes = append(mediaTypes, kind)
+	}
+
+	postURL := ""
+	if first.Chat.UserName != "" {
+		postURL = fmt.Sprintf("https://t.me/%s/%v", first.Chat.UserName, first.MessageID)
+	}
+
+	author := first.AuthorSignature
+	if first.From != nil && first.From.UserName != "" {
+		author = first.From.UserName
+	}
+
+	post := crossposter.Post{
+		Date:        time.Unix(int64(first.Date), 0),
+		URL:         postURL,
+		Author:      author,
+		Text:        strings.Join(texts, "<br>"),
+		Attachments: mediaURLs,
+		Metadata: map[string]string{
+			"message_id": strconv.Itoa(first.MessageID),
+			"chat_id":    strconv.FormatInt(first.Chat.ID, 10),
+		},
+	}
+	if len(mediaTypes) > 0 {
+		post.Metadata["media_types"] = strings.Join(mediaTypes, ",")
+	}
+	if edited {
+		post.Metadata["edited"] = "true"
+		if first.EditDate != 0 {
+			post.Date = time.Unix(int64(first.EditDate), 0)
+		}
+	}
+	for _, topic := range tg.entity.Topics {
+		crossposter.Events.Publish(topic, post)
+	}
+}
+
+// messageMedia return kind and file ID of message media
+func messageMedia(msg *message) (string, string) {
+	switch {
+	case msg.Photo != nil && len(*msg.Photo) > 0:
+		pixels := 0
+		fileID := ""
+		for _, p := range *msg.Photo { // find largest image in set
+			if pix := p.Height * p.Width; pix > pixels {
+				pixels = pix
+				fileID = p.FileID
+			}
+		}
+		return "photo", fileID
+	case msg.Video != nil:
+		return "video", msg.Video.FileID
+	case msg.Animation != nil:
+		return "animation", msg.Animation.FileID
+	case msg.VideoNote != nil:
+		return "video_note", msg.VideoNote.FileID
+	case msg.Audio != nil:
+		return "audio", msg.Audio.FileID
+	case msg.Voice != nil:
+		return "voice", msg.Voice.FileID
+	case msg.Document != nil:
+		return "document", msg.Document.FileID
+	}
+	return "", ""
+}
@@ -1,9 +1,14 @@
 package telegram
 
 import (
+	"fmt"
+	"html"
 	"regexp"
+	"sort"
 	"strings"
+	"unicode/utf16"
 
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 	"github.com/microcosm-cc/bluemonday"
 )
 
@@ -40,3 +45,87 @@ func sanitize(html string) string {
 	html = emptylines.ReplaceAllString(html, "")
 	return strings.TrimSpace(html)
 }
+
+// entitiesToHTML convert text with message entities to HTML
+func entitiesToHTML(text string, entities *[]tgbotapi.MessageEntity) string {
+	runes := utf16.Encode([]rune(text))
+	if entities == nil {
+		entities = &[]tgbotapi.MessageEntity{}
+	}
+	// Offsets of entities are in UTF-16 code units
+	opens := make(map[int][]tgbotapi.MessageEntity)
+	closes := make(map[int][]tgbotapi.MessageEntity)
+	for _, entity := range *entities {
+		if entity.Offset < 0 || entity.Length <= 0 || entity.Offset+entity.Length > len(runes) {
+			continue
+		}
+		opens[entity.Offset] = append(opens[entity.Offset], entity)
+		end := entity.Offset + entity.Length
+		closes[end] = append([]tgbotapi.MessageEntity{entity}, closes[end]...)
+	}
+
+	var b strings.Builder
+	start := 0
+	writeText := func(end int) {
+		if end > start {
+			segment := string(utf16.Decode(runes[start:end]))
+			b.WriteString(strings.ReplaceAll(html.EscapeString(segment), "\n", "<br>"))
+			start = end
+		}
+	}
+	for i := 0; i <= len(runes); i++ {
+		if len(opens[i]) == 0 && len(closes[i]) == 0 {
+			continue
+		}
+		writeText(i)
+		for _, entity := range closes[i] {
+			_, closeTag := entityTags(entity, runes)
+			b.WriteString(closeTag)
+		}
+		sort.SliceStable(opens[i], func(a, c int) bool {
+			return opens[i][a].Length > opens[i][c].Length
+		})
+		for _, entity := range opens[i] {
+			openTag, _ := entityTags(entity, runes)
+			b.WriteString(openTag)
+		}
+	}
+	writeText(len(runes))
+	return b.String()
+}
+
+// entityTags return open and close HTML tags for message entity
+func entityTags(entity tgbotapi.MessageEntity, runes []uint16) (string, string) {
+	switch entity.Type {
+	case "bold":
+		return "<b>", "</b>"
+	case "italic":
+		return "<i>", "</i>"
+	case "underline":
+		return "<u>", "</u>"
+	case "strikethrough":
+		return "<s>", "</s>"
+	case "code":
+		return "<code>", "</code>"
+	case "pre":
+		return "<pre>", "</pre>"
+	case "blockquote":
+		return "<blockquote>", "</blockquote>"
+	case "text_link":
+		return fmt.Sprintf(`<a href="%s">`, html.EscapeString(entity.URL)), "</a>"
+	case "url":
+		link := string(utf16.Decode(runes[entity.Offset : entity.Offset+entity.Length]))
+		if !strings.Contains(link, "://") {
+			link = "http://" + link
+		}
+		return fmt.Sprintf(`<a href="%s">`, html.EscapeString(link)), "</a>"
+	case "mention":
+		name := string(utf16.Decode(runes[entity.Offset+1 : entity.Offset+entity.Length]))
+		return fmt.Sprintf(`<a href="https://t.me/%s">`, html.EscapeString(name)), "</a>"
+	case "text_mention":
+		if entity.User != nil {
+			return fmt.Sprintf(`<a href="tg://user?id=%d">`, entity.User.ID), "</a>"
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,72 @@
+package telegram
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+	maxUpdateSize     = 1 << 20
+)
+
+// setWebhook register public URL of webhook in Telegram
+func (tg *Telegram) setWebhook() error {
+	webhookURL := strings.TrimRight(tg.entity.Options["webhook_url"], "/")
+	if webhookURL == "" {
+		return nil
+	}
+	params := url.Values{}
+	params.Set("url", webhookURL+"/telegram/"+tg.entity.Options["webhook"])
+	params.Set("allowed_updates", `["channel_post","edited_channel_post"]`)
+	if secret := tg.entity.Options["secret"]; secret != "" {
+		params.Set("secret_token", secret)
+	}
+	_, err := tg.client.MakeRequest("setWebhook", params)
+	return err
+}
+
+// deleteWebhook remove webhook left by previous run, otherwise getUpdates is rejected
+func (tg *Telegram) deleteWebhook() error {
+	_, err := tg.client.MakeRequest("deleteWebhook", url.Values{})
+	return err
+}
+
+// Handler accept updates pushed by Telegram to webhook
+func (tg *Telegram) Handler(w http.ResponseWriter, r *http.Request) {
+	tgLogger := log.WithFields(log.Fields{"path": r.URL.Path, "type": tg.entity.Type})
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !hmac.Equal([]byte(r.Header.Get(secretTokenHeader)), []byte(tg.entity.Options["secret"])) {
+		tgLogger.Warnf("Unauthorized update from %s", r.RemoteAddr)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var u update
+	err := json.NewDecoder(io.LimitReader(r.Body, maxUpdateSize)).Decode(&u)
+	if err != nil {
+		tgLogger.Warn(err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	select {
+	case tg.updates <- u:
+		w.WriteHeader(http.StatusOK)
+	default:
+		tgLogger.Error("Queue of updates is full")
+		http.Error(w, "queue is full", http.StatusServiceUnavailable)
+	}
+}
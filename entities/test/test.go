@@ -63,6 +63,7 @@ func (test *Test) Post(post crossposter.Post) {
 		"text":        post.Text,
 		"more":        post.More,
 		"attachments": post.Attachments,
+		"metadata":    post.Metadata,
 	}).Info("Test message")
 }
 
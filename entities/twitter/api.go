@@ -0,0 +1,471 @@
+package twitter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/garyburd/go-oauth/oauth"
+	"github.com/n0madic/crossposter/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultAPIURL    = "https://api.twitter.com"
+	defaultUploadURL = "https://upload.twitter.com/1.1/media/upload.json"
+	defaultAuthURL   = "https://twitter.com/i/oauth2/authorize"
+	maxMediaSize     = 512 << 20
+	uploadChunkSize  = 4 << 20
+)
+
+// apiClient of Twitter API v2 with OAuth 1.0a or OAuth 2.0 user context
+type apiClient struct {
+	baseURL      string
+	uploadURL    string
+	oauth        *oauth.Client
+	credentials  *oauth.Credentials
+	bearerToken  string
+	refreshToken string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	authURL      string
+	tokenFile    string
+	username     string
+	client       *http.Client
+	mutex        sync.Mutex
+	authorizing  *authorization
+}
+
+// oauthToken pair of OAuth 2.0 tokens
+type oauthToken struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// mediaResponse of upload endpoint
+type mediaResponse struct {
+	MediaIDString  string `json:"media_id_string"`
+	ProcessingInfo *struct {
+		State          string `json:"state"`
+		CheckAfterSecs int    `json:"check_after_secs"`
+		Error          *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	} `json:"processing_info"`
+	Data struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+// id of uploaded media from v1.1 or v2 response
+func (m *mediaResponse) id() string {
+	if m.MediaIDString == "" {
+		return m.Data.ID
+	}
+	return m.MediaIDString
+}
+
+type apiError struct {
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// newAPIClient return client configured with entity options
+func newAPIClient(options map[string]string) (*apiClient, error) {
+	c := &apiClient{
+		baseURL:      strings.TrimRight(options["api_url"], "/"),
+		uploadURL:    options["upload_url"],
+		bearerToken:  options["bearer_token"],
+		refreshToken: options["refresh_token"],
+		clientID:     options["client_id"],
+		clientSecret: options["client_secret"],
+		redirectURL:  options["redirect_url"],
+		authURL:      options["auth_url"],
+		tokenFile:    options["token_storage"],
+		client:       &http.Client{Timeout: time.Minute},
+	}
+	if c.baseURL == "" {
+		c.baseURL = defaultAPIURL
+	}
+	if c.uploadURL == "" {
+		c.uploadURL = defaultUploadURL
+	}
+	if c.authURL == "" {
+		c.authURL = defaultAuthURL
+	}
+	if options["key"] != "" && options["token"] != "" {
+		c.oauth = &oauth.Client{
+			Credentials: oauth.Credentials{Token: options["key"], Secret: options["key_secret"]},
+		}
+		c.credentials = &oauth.Credentials{Token: options["token"], Secret: options["token_secret"]}
+	}
+	if c.tokenFile != "" {
+		err := c.loadTokens()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// loadTokens restore OAuth 2.0 tokens rotated by previous run
+func (c *apiClient) loadTokens() error {
+	content, err := ioutil.ReadFile(c.tokenFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var token oauthToken
+	err = json.Unmarshal(content, &token)
+	if err != nil {
+		return fmt.Errorf("can't load Twitter tokens: %v", err)
+	}
+	if token.AccessToken != "" {
+		c.bearerToken = token.AccessToken
+	}
+	if token.RefreshToken != "" {
+		c.refreshToken = token.RefreshToken
+	}
+	return nil
+}
+
+// saveTokens keep rotated OAuth 2.0 tokens, refresh token can be used only once
+func (c *apiClient) saveTokens() error {
+	data, err := json.Marshal(oauthToken{AccessToken: c.bearerToken, RefreshToken: c.refreshToken})
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(c.tokenFile, data, 0600)
+}
+
+// authorize set authorization header for request
+func (c *apiClient) authorize(req *http.Request, form url.Values) error {
+	if c.oauth != nil {
+		return c.oauth.SetAuthorizationHeader(req.Header, c.credentials, req.Method, req.URL, form)
+	}
+	if c.bearerToken == "" {
+		return fmt.Errorf("no Twitter credentials configured")
+	}
+	req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	return nil
+}
+
+// refresh OAuth 2.0 access token with refresh token
+func (c *apiClient) refresh() error {
+	if c.refreshToken == "" || c.clientID == "" {
+		return fmt.Errorf("access token expired and can't be refreshed")
+	}
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", c.refreshToken)
+	return c.requestToken(form)
+}
+
+// requestToken get new OAuth 2.0 tokens and save them
+func (c *apiClient) requestToken(form url.Values) error {
+	form.Set("client_id", c.clientID)
+	req, err := http.NewRequest("POST", c.baseURL+"/2/oauth2/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if c.clientSecret != "" {
+		req.SetBasicAuth(c.clientID, c.clientSecret)
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	var token oauthToken
+	err = json.NewDecoder(res.Body).Decode(&token)
+	if err != nil || token.AccessToken == "" {
+		return fmt.Errorf("can't get access token: %s", res.Status)
+	}
+	c.bearerToken = token.AccessToken
+	if token.RefreshToken != "" {
+		c.refreshToken = token.RefreshToken
+	}
+	if c.tokenFile != "" {
+		if err := c.saveTokens(); err != nil {
+			log.WithFields(log.Fields{"file": c.tokenFile}).Errorf("Can't save refreshed Twitter tokens: %v", err)
+		}
+	}
+	return nil
+}
+
+// request Twitter API with JSON body and decode response to target
+func (c *apiClient) request(method, path string, body interface{}, target interface{}) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(method, c.baseURL+path, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		err = c.authorize(req, nil)
+		if err != nil {
+			return err
+		}
+
+		res, err := c.client.Do(req)
+		if err != nil {
+			return err
+		}
+		if res.StatusCode == http.StatusUnauthorized && c.oauth == nil && attempt == 0 {
+			res.Body.Close()
+			err = c.refresh()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		return decodeResponse(res, target)
+	}
+}
+
+// me return username of authorized user
+func (c *apiClient) me() (string, error) {
+	if c.username != "" {
+		return c.username, nil
+	}
+	var response struct {
+		Data struct {
+			Username string `json:"username"`
+		} `json:"data"`
+	}
+	err := c.request("GET", "/2/users/me", nil, &response)
+	if err != nil {
+		return "", err
+	}
+	c.username = response.Data.Username
+	return c.username, nil
+}
+
+// tweet create status optionally in reply to other tweet and return its ID
+func (c *apiClient) tweet(text string, mediaIDs []string, replyTo string) (string, error) {
+	body := map[string]interface{}{"text": text}
+	if len(mediaIDs) > 0 {
+		body["media"] = map[string][]string{"media_ids": mediaIDs}
+	}
+	if replyTo != "" {
+		body["reply"] = map[string]string{"in_reply_to_tweet_id": replyTo}
+	}
+	var response struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	err := c.request("POST", "/2/tweets", body, &response)
+	if err != nil {
+		return "", err
+	}
+	return response.Data.ID, nil
+}
+
+// uploadMedia upload file from URL and return media ID with kind of media,
+// videos and animations are uploaded in chunks
+func (c *apiClient) uploadMedia(mediaURL string) (string, string, error) {
+	res, err := c.client.Get(mediaURL)
+	if err != nil {
+		return "", "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("can't download %s: %s", mediaURL, res.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, maxMediaSize+1))
+	if err != nil {
+		return "", "", err
+	}
+	if len(data) > maxMediaSize {
+		return "", "", fmt.Errorf("media %s is too large", mediaURL)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType == "" || mediaType == "application/octet-stream" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(data))
+	}
+	switch {
+	case mediaType == "image/gif":
+		id, err := c.uploadChunked(data, mediaType, "tweet_gif")
+		return id, "video", err
+	case strings.HasPrefix(mediaType, "image/"):
+		id, err := c.uploadSimple(data)
+		return id, "photo", err
+	case strings.HasPrefix(mediaType, "video/"):
+		id, err := c.uploadChunked(data, mediaType, "tweet_video")
+		return id, "video", err
+	}
+	return "", "", fmt.Errorf("unsupported media type %s of %s", mediaType, mediaURL)
+}
+
+// uploadSimple upload image in one request
+func (c *apiClient) uploadSimple(data []byte) (string, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("media", "media")
+	if err != nil {
+		return "", err
+	}
+	_, err = part.Write(data)
+	if err != nil {
+		return "", err
+	}
+	err = writer.Close()
+	if err != nil {
+		return "", err
+	}
+
+	var media mediaResponse
+	err = c.upload("POST", nil, body, writer.FormDataContentType(), &media)
+	return media.id(), err
+}
+
+// uploadChunked upload media with INIT, APPEND and FINALIZE commands
+// and wait for its processing
+func (c *apiClient) uploadChunked(data []byte, mediaType, category string) (string, error) {
+	var media mediaResponse
+	err := c.upload("POST", url.Values{
+		"command":        {"INIT"},
+		"total_bytes":    {strconv.Itoa(len(data))},
+		"media_type":     {mediaType},
+		"media_category": {category},
+	}, nil, "", &media)
+	if err != nil {
+		return "", err
+	}
+	id := media.id()
+
+	for segment, offset := 0, 0; offset < len(data); segment++ {
+		end := offset + uploadChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		writer.WriteField("command", "APPEND")
+		writer.WriteField("media_id", id)
+		writer.WriteField("segment_index", strconv.Itoa(segment))
+		part, err := writer.CreateFormFile("media", "media")
+		if err != nil {
+			return "", err
+		}
+		part.Write(data[offset:end])
+		err = writer.Close()
+		if err != nil {
+			return "", err
+		}
+		err = c.upload("POST", nil, body, writer.FormDataContentType(), nil)
+		if err != nil {
+			return "", err
+		}
+		offset = end
+	}
+
+	media = mediaResponse{}
+	err = c.upload("POST", url.Values{"command": {"FINALIZE"}, "media_id": {id}}, nil, "", &media)
+	for err == nil && media.ProcessingInfo != nil {
+		switch media.ProcessingInfo.State {
+		case "succeeded":
+			return id, nil
+		case "failed":
+			if media.ProcessingInfo.Error != nil {
+				return "", fmt.Errorf("media processing failed: %s", media.ProcessingInfo.Error.Message)
+			}
+			return "", fmt.Errorf("media processing failed")
+		}
+		time.Sleep(time.Duration(media.ProcessingInfo.CheckAfterSecs+1) * time.Second)
+		media = mediaResponse{}
+		err = c.upload("GET", url.Values{"command": {"STATUS"}, "media_id": {id}}, nil, "", &media)
+	}
+	return id, err
+}
+
+// upload send request to upload endpoint with form parameters or multipart body
+func (c *apiClient) upload(method string, form url.Values, body io.Reader, contentType string, target interface{}) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	uploadURL := c.uploadURL
+	switch {
+	case method == "GET" && form != nil:
+		uploadURL += "?" + form.Encode()
+		form = nil
+	case form != nil:
+		body = strings.NewReader(form.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	}
+	req, err := http.NewRequest(method, uploadURL, body)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	err = c.authorize(req, form)
+	if err != nil {
+		return err
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	return decodeResponse(res, target)
+}
+
+// decodeResponse check status and decode JSON body of response
+func decodeResponse(res *http.Response, target interface{}) error {
+	defer res.Body.Close()
+	content, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode >= 300 {
+		var apiErr apiError
+		if json.Unmarshal(content, &apiErr) == nil {
+			switch {
+			case apiErr.Detail != "":
+				return fmt.Errorf("%s: %s", res.Status, apiErr.Detail)
+			case len(apiErr.Errors) > 0:
+				return fmt.Errorf("%s: %s", res.Status, apiErr.Errors[0].Message)
+			}
+		}
+		return fmt.Errorf("bad status: %s", res.Status)
+	}
+	if target == nil {
+		return nil
+	}
+	return json.Unmarshal(content, target)
+}
@@ -0,0 +1,110 @@
+package twitter
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestRequestRefreshesExpiredToken(t *testing.T) {
+	var refreshes, tweets int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/2/oauth2/token":
+			refreshes++
+			r.ParseForm()
+			if r.Form.Get("grant_type") != "refresh_token" || r.Form.Get("refresh_token") != "old-refresh" {
+				t.Errorf("unexpected token request: %v", r.Form)
+			}
+			json.NewEncoder(w).Encode(oauthToken{AccessToken: "new-access", RefreshToken: "new-refresh"})
+		case "/2/tweets":
+			tweets++
+			if r.Header.Get("Authorization") != "Bearer new-access" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"data":{"id":"42"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "tokens.json")
+	c, err := newAPIClient(map[string]string{
+		"api_url":       server.URL,
+		"bearer_token":  "old-access",
+		"refresh_token": "old-refresh",
+		"client_id":     "client",
+		"token_storage": tokenFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := c.tweet("hello", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "42" || refreshes != 1 || tweets != 2 {
+		t.Errorf("id = %s, refreshes = %d, tweets = %d", id, refreshes, tweets)
+	}
+
+	// Rotated tokens are used by new client instead of configured ones
+	restored, err := newAPIClient(map[string]string{
+		"api_url":       server.URL,
+		"bearer_token":  "old-access",
+		"refresh_token": "old-refresh",
+		"token_storage": tokenFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.bearerToken != "new-access" || restored.refreshToken != "new-refresh" {
+		t.Errorf("restored tokens = %s, %s", restored.bearerToken, restored.refreshToken)
+	}
+}
+
+func TestRequestFailsWithoutRefreshToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	c, err := newAPIClient(map[string]string{"api_url": server.URL, "bearer_token": "expired"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.tweet("hello", nil, ""); err == nil {
+		t.Error("expected error for expired token")
+	}
+}
+
+func TestTokenStorage(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "tokens.json")
+	c := &apiClient{bearerToken: "access", refreshToken: "refresh", tokenFile: tokenFile}
+	if err := c.saveTokens(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := &apiClient{tokenFile: tokenFile}
+	if err := loaded.loadTokens(); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.bearerToken != "access" || loaded.refreshToken != "refresh" {
+		t.Errorf("loaded tokens = %s, %s", loaded.bearerToken, loaded.refreshToken)
+	}
+
+	missing := &apiClient{tokenFile: filepath.Join(t.TempDir(), "missing.json"), bearerToken: "configured"}
+	if err := missing.loadTokens(); err != nil || missing.bearerToken != "configured" {
+		t.Errorf("missing storage: err = %v, token = %s", err, missing.bearerToken)
+	}
+
+	ioutil.WriteFile(tokenFile, []byte("{"), 0600)
+	if err := loaded.loadTokens(); err == nil {
+		t.Error("expected error for broken storage")
+	}
+}
@@ -0,0 +1,123 @@
+package twitter
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	authScopes  = "tweet.read tweet.write users.read offline.access"
+	authTimeout = 10 * time.Minute
+)
+
+// authorization of OAuth 2.0 PKCE flow waiting for callback
+type authorization struct {
+	state    string
+	verifier string
+	started  time.Time
+}
+
+// randomString return URL safe random string
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// startAuthorization prepare PKCE challenge and return URL of consent page
+func (c *apiClient) startAuthorization() (string, error) {
+	state, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+
+	c.mutex.Lock()
+	c.authorizing = &authorization{state: state, verifier: verifier, started: time.Now()}
+	c.mutex.Unlock()
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", c.clientID)
+	params.Set("redirect_uri", c.redirectURL)
+	params.Set("scope", authScopes)
+	params.Set("state", state)
+	params.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	params.Set("code_challenge_method", "S256")
+	return c.authURL + "?" + params.Encode(), nil
+}
+
+// finishAuthorization exchange code from callback to tokens
+func (c *apiClient) finishAuthorization(state, code string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	auth := c.authorizing
+	if auth == nil || time.Since(auth.started) > authTimeout ||
+		!hmac.Equal([]byte(state), []byte(auth.state)) {
+		return fmt.Errorf("unknown or expired authorization state")
+	}
+	c.authorizing = nil
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.redirectURL)
+	form.Set("code_verifier", auth.verifier)
+	err := c.requestToken(form)
+	if err == nil {
+		c.username = ""
+	}
+	return err
+}
+
+// Handler authorize account with OAuth 2.0 PKCE flow,
+// flow is started with secret parameter and finished by callback with code
+func (tw *Twitter) Handler(w http.ResponseWriter, r *http.Request) {
+	twLogger := log.WithFields(log.Fields{"path": r.URL.Path, "type": tw.entity.Type})
+	query := r.URL.Query()
+
+	if code := query.Get("code"); code != "" {
+		err := tw.api.finishAuthorization(query.Get("state"), code)
+		if err != nil {
+			twLogger.Warnf("Authorization failed: %s", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		twLogger.Println("Account authorized")
+		fmt.Fprintln(w, "Account authorized")
+		return
+	}
+	if e := query.Get("error"); e != "" {
+		twLogger.Warnf("Authorization denied: %s", e)
+		http.Error(w, "authorization denied: "+e, http.StatusForbidden)
+		return
+	}
+
+	if !hmac.Equal([]byte(query.Get("secret")), []byte(tw.entity.Options["auth_secret"])) {
+		twLogger.Warnf("Unauthorized request from %s", r.RemoteAddr)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	authURL, err := tw.api.startAuthorization()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
@@ -0,0 +1,100 @@
+package twitter
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/ChimeraCoder/anaconda"
+	"github.com/n0madic/crossposter"
+	"github.com/n0madic/crossposter/utils"
+)
+
+// tweetURL return permanent link of tweet
+func tweetURL(tweet *anaconda.Tweet) string {
+	return fmt.Sprintf("https://twitter.com/%s/status/%s", tweet.User.ScreenName, tweet.IdStr)
+}
+
+// tweetMedia return photo URLs and best variants of video and GIF
+func tweetMedia(tweet *anaconda.Tweet) []string {
+	media := tweet.ExtendedEntities.Media
+	if len(media) == 0 {
+		media = tweet.Entities.Media
+	}
+	mediaURLs := []string{}
+	for _, m := range media {
+		mediaURL := m.Media_url_https
+		if m.Type == "video" || m.Type == "animated_gif" {
+			bitrate := -1
+			for _, variant := range m.VideoInfo.Variants {
+				if variant.ContentType == "video/mp4" && variant.Bitrate > bitrate {
+					bitrate = variant.Bitrate
+					mediaURL = variant.Url
+				}
+			}
+		}
+		if mediaURL != "" && !utils.StringInSlice(mediaURL, mediaURLs) {
+			mediaURLs = append(mediaURLs, mediaURL)
+		}
+	}
+	return mediaURLs
+}
+
+// tweetText return text of tweet with expanded links and without media links
+func tweetText(tweet *anaconda.Tweet) string {
+	text := tweet.FullText
+	if text == "" {
+		text = tweet.Text
+	}
+	var replacements []string
+	for _, u := range tweet.Entities.Urls {
+		expanded := u.Expanded_url
+		if tweet.QuotedStatus != nil && strings.HasSuffix(expanded, "/status/"+tweet.QuotedStatusIdStr) {
+			expanded = ""
+		}
+		replacements = append(replacements, u.Url, expanded)
+	}
+	for _, m := range tweet.Entities.Media {
+		replacements = append(replacements, m.Url, "")
+	}
+	for _, m := range tweet.ExtendedEntities.Media {
+		replacements = append(replacements, m.Url, "")
+	}
+	if len(replacements) > 0 {
+		text = strings.NewReplacer(replacements...).Replace(text)
+	}
+	return strings.TrimSpace(html.UnescapeString(text))
+}
+
+// tweetToPost convert tweet with retweeted and quoted statuses to post
+func tweetToPost(tweet *anaconda.Tweet) crossposter.Post {
+	timestamp, _ := tweet.CreatedAtTime()
+	original := tweet
+	metadata := map[string]string{"id": tweet.IdStr}
+	if tweet.RetweetedStatus != nil {
+		original = tweet.RetweetedStatus
+		metadata["retweeted_by"] = tweet.User.ScreenName
+	}
+
+	text := tweetText(original)
+	mediaURLs := tweetMedia(original)
+	if quoted := original.QuotedStatus; quoted != nil {
+		text += fmt.Sprintf("\n\n@%s: %s\n%s", quoted.User.ScreenName, tweetText(quoted), tweetURL(quoted))
+		for _, m := range tweetMedia(quoted) {
+			if !utils.StringInSlice(m, mediaURLs) {
+				mediaURLs = append(mediaURLs, m)
+			}
+		}
+		metadata["quoted"] = quoted.IdStr
+	}
+
+	return crossposter.Post{
+		Date:        timestamp,
+		URL:         tweetURL(original),
+		Author:      original.User.ScreenName,
+		Text:        text,
+		Attachments: mediaURLs,
+		More:        false,
+		Metadata:    metadata,
+	}
+}
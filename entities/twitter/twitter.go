@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/url"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,87 +16,120 @@ import (
 )
 
 const shortURLLength = 23
-const maxTweetLength = 140
+const maxTweetLength = 280
 const maxPhotoLimit = 4
 
 // Twitter entity
 type Twitter struct {
 	entity *crossposter.Entity
 	client *anaconda.TwitterApi
+	api    *apiClient
 }
 
+// Paths of OAuth 2.0 authorization callbacks
+var authPaths = make(map[string]bool)
+
 func init() {
 	crossposter.AddEntity("twitter", New)
 }
 
 // New return Twitter entity
 func New(entity crossposter.Entity) (crossposter.EntityInterface, error) {
-	client := anaconda.NewTwitterApiWithCredentials(
-		entity.Options["token"],
-		entity.Options["token_secret"],
-		entity.Options["key"],
-		entity.Options["key_secret"],
-	)
-	ok, err := client.VerifyCredentials()
-	if !ok {
-		return nil, fmt.Errorf("can't create new TwitterAPI: %v", err)
+	api, err := newAPIClient(entity.Options)
+	if err != nil {
+		return nil, err
+	}
+	tw := &Twitter{entity: &entity, api: api}
+	if api.redirectURL != "" {
+		redirect, err := url.Parse(api.redirectURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redirect_url: %v", err)
+		}
+		if api.clientID == "" || api.tokenFile == "" || entity.Options["auth_secret"] == "" {
+			return nil, fmt.Errorf("authorization with redirect_url requires client_id, token_storage and auth_secret")
+		}
+		if authPaths[redirect.Path] {
+			return nil, fmt.Errorf("path %s of Twitter authorization already used", redirect.Path)
+		}
+		authPaths[redirect.Path] = true
+		http.HandleFunc(redirect.Path, tw.Handler)
+	}
+	if len(entity.Sources) > 0 {
+		tw.client = anaconda.NewTwitterApiWithCredentials(
+			entity.Options["token"],
+			entity.Options["token_secret"],
+			entity.Options["key"],
+			entity.Options["key_secret"],
+		)
+		ok, err := tw.client.VerifyCredentials()
+		if !ok {
+			return nil, fmt.Errorf("can't create new TwitterAPI: %v", err)
+		}
 	}
-	return &Twitter{&entity, client}, nil
+	return tw, nil
 }
 
 // Get user's timeline from Twitter
 func (tw *Twitter) Get(lastUpdate time.Time) {
 	defer crossposter.WaitGroup.Done()
+	checkpoints := crossposter.NewCheckpoints(lastUpdate)
 
 	for {
 		for _, screenName := range tw.entity.Sources {
 			twLogger := log.WithFields(log.Fields{"name": screenName, "type": tw.entity.Type})
 			twLogger.Println("Check updates")
 			v := url.Values{}
-			v.Set("count", "10")
+			v.Set("count", "20")
+			if count := tw.entity.Option("count", screenName); count != "" {
+				v.Set("count", count)
+			}
 			v.Set("screen_name", screenName)
+			v.Set("tweet_mode", "extended")
 
 			tweets, err := tw.client.GetUserTimeline(v)
 			if err != nil {
 				twLogger.Error(err)
-			} else {
-				sort.Slice(tweets, func(i, j int) bool {
-					itime, _ := tweets[i].CreatedAtTime()
-					jtime, _ := tweets[j].CreatedAtTime()
-					return itime.Before(jtime)
-				})
-
-				for _, tweet := range tweets {
-					timestamp, _ := tweet.CreatedAtTime()
-					if tweet.InReplyToUserID == 0 && timestamp.After(lastUpdate) {
-						lastUpdate = timestamp
-						mediaURLs := []string{}
-						for _, media := range tweet.Entities.Media {
-							// if media.Type == "photo" || media.Type == "animated_gif" {
-							mediaURLs = append(mediaURLs, media.Media_url_https)
-							// }
-						}
-						text := tweet.FullText
-						if tweet.RetweetedStatus != nil {
-							text = tweet.RetweetedStatus.FullText
-							for _, media := range tweet.RetweetedStatus.Entities.Media {
-								if !utils.StringInSlice(media.Media_url_https, mediaURLs) {
-									mediaURLs = append(mediaURLs, media.Media_url_https)
-								}
-							}
-						}
-						post := crossposter.Post{
-							Date:        timestamp,
-							URL:         fmt.Sprintf("https://twitter.com/%s/status/%s", screenName, tweet.IdStr),
-							Author:      tweet.User.ScreenName,
-							Text:        text,
-							Attachments: mediaURLs,
-							More:        false,
-						}
-						for _, topic := range tw.entity.Topics {
-							crossposter.Events.Publish(topic, post)
+				continue
+			}
+			sort.Slice(tweets, func(i, j int) bool {
+				itime, _ := tweets[i].CreatedAtTime()
+				jtime, _ := tweets[j].CreatedAtTime()
+				return itime.Before(jtime)
+			})
+
+			var posts []*crossposter.Post
+			threads := make(map[string]*crossposter.Post)
+			for i := range tweets {
+				tweet := &tweets[i]
+				timestamp, _ := tweet.CreatedAtTime()
+				if !checkpoints.IsNew(screenName, timestamp) {
+					continue
+				}
+				selfReply := tweet.InReplyToUserID != 0 && tweet.InReplyToUserID == tweet.User.Id
+				if tweet.InReplyToUserID != 0 && !selfReply {
+					continue
+				}
+				checkpoints.Update(screenName, timestamp)
+
+				post := tweetToPost(tweet)
+				// Stitch replies to self into thread of first tweet
+				if root, ok := threads[tweet.InReplyToStatusIdStr]; ok && selfReply {
+					root.Text += "\n\n" + post.Text
+					for _, m := range post.Attachments {
+						if !utils.StringInSlice(m, root.Attachments) {
+							root.Attachments = append(root.Attachments, m)
 						}
 					}
+					threads[tweet.IdStr] = root
+					continue
+				}
+				threads[tweet.IdStr] = &post
+				posts = append(posts, &post)
+			}
+
+			for _, post := range posts {
+				for _, topic := range tw.entity.Topics {
+					crossposter.Events.Publish(topic, *post)
 				}
 			}
 		}
@@ -106,41 +140,64 @@ func (tw *Twitter) Get(lastUpdate time.Time) {
 // Post status to Twitter
 func (tw *Twitter) Post(post crossposter.Post) {
 	var mediaIDs []string
-	v := url.Values{}
 
-	user, err := tw.client.GetSelf(v)
-	twLogger := log.WithFields(log.Fields{"name": user.ScreenName, "type": tw.entity.Type})
+	user, err := tw.api.me()
+	twLogger := log.WithFields(log.Fields{"name": user, "type": tw.entity.Type})
+	if err != nil {
+		twLogger.Error(err)
+		return
+	}
 
-	status := TwitterizeText(post.Text)
-	if strings.HasSuffix(status, "…") || post.More {
-		status += " " + post.URL
+	var statuses []string
+	if thread, _ := strconv.ParseBool(tw.entity.Options["thread"]); thread {
+		text := post.Text
+		if post.URL != "" && (post.More || TweetLength(text) > maxTweetLength) {
+			text += " " + post.URL
+		}
+		statuses = SplitThread(text)
+		if len(statuses) == 0 {
+			statuses = []string{""}
+		}
+	} else {
+		status := TwitterizeText(post.Text)
+		if post.URL != "" && (strings.HasSuffix(status, "…") || post.More) {
+			status += " " + post.URL
+		}
+		statuses = []string{strings.TrimSpace(status)}
 	}
 
-	for index, attach := range post.Attachments {
-		if b64, err := utils.GetURLContentInBase64(attach); err == nil {
-			media, err := tw.client.UploadMedia(b64)
-			if err != nil {
-				twLogger.Error(err)
-				return
+	// Tweet has up to four photos or single video
+	for _, attach := range post.Attachments {
+		mediaID, kind, err := tw.api.uploadMedia(attach)
+		if err != nil {
+			twLogger.Warnf("Skip attachment %s: %s", attach, err)
+			continue
+		}
+		if kind == "video" {
+			if len(mediaIDs) > 0 {
+				twLogger.Warnf("Skip attachment %s: video can't be mixed with photos", attach)
+				continue
 			}
-			mediaIDs = append(mediaIDs, media.MediaIDString)
-		} else {
-			twLogger.Error(err)
-			return
+			mediaIDs = []string{mediaID}
+			break
 		}
-		if index == maxPhotoLimit-1 {
+		mediaIDs = append(mediaIDs, mediaID)
+		if len(mediaIDs) == maxPhotoLimit {
 			break
 		}
 	}
 
-	v.Set("media_ids", strings.Join(mediaIDs[:], ","))
-	result, err := tw.client.PostTweet(strings.TrimSpace(status), v)
-	if err != nil {
-		twLogger.Error(err)
-	} else {
-		twLogger.Printf("Posted tweet https://twitter.com/%s/status/%s", result.User.ScreenName, result.IdStr)
+	replyTo := ""
+	for i, status := range statuses {
+		if i > 0 {
+			mediaIDs = nil
+		}
+		id, err := tw.api.tweet(status, mediaIDs, replyTo)
+		if err != nil {
+			twLogger.Error(err)
+			return
+		}
+		twLogger.Printf("Posted tweet https://twitter.com/%s/status/%s", user, id)
+		replyTo = id
 	}
 }
-
-// Handler not implemented
-func (tw *Twitter) Handler(w http.ResponseWriter, r *http.Request) {}
@@ -2,14 +2,58 @@ package twitter
 
 import (
 	"strings"
-	"unicode/utf8"
+	"unicode"
 
 	"github.com/n0madic/crossposter/utils"
 )
 
+// Ranges of code points with single weight in twitter-text configuration
+var lightRanges = [][2]rune{
+	{0x0000, 0x10FF},
+	{0x2000, 0x200D},
+	{0x2010, 0x201F},
+	{0x2032, 0x2037},
+}
+
+// runeWeight return weight of character in tweet length
+func runeWeight(r rune) int {
+	for _, lr := range lightRanges {
+		if r >= lr[0] && r <= lr[1] {
+			return 1
+		}
+	}
+	return 2
+}
+
+// wordLength return weighted length of word with URLs shortened by t.co
+func wordLength(word string) int {
+	if utils.IsRequestURL(word) {
+		return shortURLLength
+	}
+	length := 0
+	for _, r := range word {
+		length += runeWeight(r)
+	}
+	return length
+}
+
+// TweetLength return weighted length of text counted by Twitter
+func TweetLength(text string) int {
+	length := 0
+	for _, word := range strings.Fields(text) {
+		length += wordLength(word)
+	}
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			length += runeWeight(r)
+		}
+	}
+	return length
+}
+
 // TwitterizeText prepares text for twitter status
 func TwitterizeText(input string) string {
-	if utf8.RuneCountInString(input) <= maxTweetLength {
+	if TweetLength(input) <= maxTweetLength {
 		return input
 	}
 	truncatedText := ""
@@ -17,11 +61,7 @@ func TwitterizeText(input string) string {
 	maxAvailableLength := maxTweetLength - shortURLLength - 2
 	words := strings.Fields(input)
 	for _, word := range words {
-		if utils.IsRequestURL(word) {
-			currentTweetLength += shortURLLength
-		} else {
-			currentTweetLength += utf8.RuneCountInString(word)
-		}
+		currentTweetLength += wordLength(word)
 		if currentTweetLength < maxAvailableLength {
 			truncatedText += word + " "
 			currentTweetLength++
@@ -32,3 +72,40 @@ func TwitterizeText(input string) string {
 	}
 	return strings.TrimSpace(truncatedText)
 }
+
+// SplitThread split text to parts fitting in tweets
+func SplitThread(input string) []string {
+	var parts []string
+	current := ""
+	currentLength := 0
+	for _, word := range strings.Fields(input) {
+		length := wordLength(word)
+		if current != "" && currentLength+1+length > maxTweetLength {
+			parts = append(parts, current)
+			current, currentLength = "", 0
+		}
+		if current != "" {
+			current += " "
+			currentLength++
+		}
+		// Split too long word without spaces
+		for length > maxTweetLength {
+			runes := []rune(word)
+			cut, cutLength := 0, 0
+			for cut < len(runes) && cutLength+runeWeight(runes[cut]) <= maxTweetLength-currentLength {
+				cutLength += runeWeight(runes[cut])
+				cut++
+			}
+			parts = append(parts, current+string(runes[:cut]))
+			current, currentLength = "", 0
+			word = string(runes[cut:])
+			length = wordLength(word)
+		}
+		current += word
+		currentLength += length
+	}
+	if current != "" {
+		parts = append(parts, current)
+	}
+	return parts
+}
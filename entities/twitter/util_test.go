@@ -0,0 +1,61 @@
+package twitter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTweetLength(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{"empty", "", 0},
+		{"latin", "hello world", 11},
+		{"cyrillic", "привет", 6},
+		{"cjk", "日本", 4},
+		{"url", "read https://example.com/very/long/path/to/article", 5 + shortURLLength},
+		{"url after newline", "read\nhttps://example.com/very/long/path/to/article", 5 + shortURLLength},
+		{"url after double space", "a  https://example.com/x", 3 + shortURLLength},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TweetLength(tt.text); got != tt.want {
+				t.Errorf("TweetLength(%q) = %d, want %d", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitThread(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		parts int
+		// words are kept whole when text has no too long words
+		whole bool
+	}{
+		{"empty", "", 0, true},
+		{"short", "hello world", 1, true},
+		{"two tweets", strings.Repeat("word ", 80), 2, true},
+		{"long word", strings.Repeat("x", maxTweetLength*2+10), 3, false},
+		{"cjk", strings.Repeat("日", maxTweetLength), 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := SplitThread(tt.text)
+			if len(parts) != tt.parts {
+				t.Fatalf("SplitThread() returned %d parts, want %d", len(parts), tt.parts)
+			}
+			for _, part := range parts {
+				if TweetLength(part) > maxTweetLength {
+					t.Errorf("part is too long: %d", TweetLength(part))
+				}
+			}
+			if tt.whole && strings.Join(parts, " ") != strings.Join(strings.Fields(tt.text), " ") {
+				t.Errorf("joined parts differ from text")
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package vk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/n0madic/crossposter/utils"
+)
+
+// attachmentKind detect kind of VK attachment by file extension or content type of URL
+func attachmentKind(rawurl string) string {
+	mediaType := utils.MediaType(rawurl)
+	switch {
+	case mediaType == "":
+		return "photo"
+	case strings.HasPrefix(mediaType, "image/gif"):
+		return "doc"
+	case strings.HasPrefix(mediaType, "image/"):
+		return "photo"
+	case strings.HasPrefix(mediaType, "video/"):
+		return "video"
+	}
+	return "doc"
+}
+
+// download attachment to temporary file
+func download(attach string) (string, error) {
+	name := "attachment"
+	if u, err := url.Parse(attach); err == nil && path.Base(u.Path) != "/" && path.Base(u.Path) != "." {
+		name = path.Base(u.Path)
+	}
+	file, err := ioutil.TempFile("", "vk-*-"+name)
+	if err != nil {
+		return "", err
+	}
+	file.Close()
+	err = utils.DownloadFile(attach, file.Name())
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// uploadFile send file to upload server and decode response
+func (vk *Vk) uploadFile(uploadURL, field, filePath string, target interface{}) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile(field, path.Base(filePath))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, file)
+	if err != nil {
+		return err
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+
+	res, err := vk.client.Client.Post(uploadURL, writer.FormDataContentType(), body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("upload failed: %s", res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(target)
+}
+
+// uploadDoc upload document to group wall and return attachment string
+func (vk *Vk) uploadDoc(groupID int, filePath string) (string, error) {
+	params := url.Values{}
+	params.Set("group_id", strconv.Itoa(groupID))
+	resp, err := vk.client.MakeRequest("docs.getWallUploadServer", params)
+	if err != nil {
+		return "", err
+	}
+	var server struct {
+		UploadURL string `json:"upload_url"`
+	}
+	err = json.Unmarshal(resp.Response, &server)
+	if err != nil {
+		return "", err
+	}
+
+	var uploaded struct {
+		File  string `json:"file"`
+		Error string `json:"error"`
+	}
+	err = vk.uploadFile(server.UploadURL, "file", filePath, &uploaded)
+	if err != nil {
+		return "", err
+	}
+	if uploaded.File == "" {
+		return "", fmt.Errorf("can't upload document: %s", uploaded.Error)
+	}
+
+	params = url.Values{}
+	params.Set("file", uploaded.File)
+	resp, err = vk.client.MakeRequest("docs.save", params)
+	if err != nil {
+		return "", err
+	}
+	var saved struct {
+		Doc struct {
+			ID      int `json:"id"`
+			OwnerID int `json:"owner_id"`
+		} `json:"doc"`
+	}
+	err = json.Unmarshal(resp.Response, &saved)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("doc%d_%d", saved.Doc.OwnerID, saved.Doc.ID), nil
+}
+
+// uploadVideo upload video to group with video.save and return attachment string
+func (vk *Vk) uploadVideo(groupID int, filePath, name string) (string, error) {
+	params := url.Values{}
+	params.Set("group_id", strconv.Itoa(groupID))
+	params.Set("name", name)
+	params.Set("wallpost", "0")
+	resp, err := vk.client.MakeRequest("video.save", params)
+	if err != nil {
+		return "", err
+	}
+	var saved struct {
+		UploadURL string `json:"upload_url"`
+		VideoID   int    `json:"video_id"`
+		OwnerID   int    `json:"owner_id"`
+	}
+	err = json.Unmarshal(resp.Response, &saved)
+	if err != nil {
+		return "", err
+	}
+
+	var uploaded struct {
+		Error string `json:"error"`
+	}
+	err = vk.uploadFile(saved.UploadURL, "video_file", filePath, &uploaded)
+	if err != nil {
+		return "", err
+	}
+	if uploaded.Error != "" {
+		return "", fmt.Errorf("can't upload video: %s", uploaded.Error)
+	}
+	return fmt.Sprintf("video%d_%d", saved.OwnerID, saved.VideoID), nil
+}
@@ -2,8 +2,11 @@ package vk
 
 import (
 	"regexp"
+	"strings"
 
+	"github.com/PuerkitoBio/goquery"
 	vkapi "github.com/himidori/golang-vk-api"
+	"golang.org/x/net/html"
 )
 
 var reInternalURLs = regexp.MustCompile(`\[(.+?)\|(.+?)\]`)
@@ -33,3 +36,77 @@ func getMaxPreview(v vkapi.VideoAttachment) string {
 	}
 	return url
 }
+
+// userName return full name of user with nickname
+func userName(user *vkapi.User) string {
+	name := user.FirstName + " " + user.LastName
+	if user.Nickname != "" {
+		name += " aka " + user.Nickname
+	}
+	return strings.TrimSpace(name)
+}
+
+var reExtraNewlines = regexp.MustCompile(`\n{3,}`)
+
+// htmlToText convert HTML to plain VK text with links in brackets
+func htmlToText(content string) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return content
+	}
+
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			switch n.Data {
+			case "br":
+				b.WriteString("\n")
+				return
+			case "img", "script", "style":
+				return
+			case "a":
+				text := strings.TrimSpace(goquery.NewDocumentFromNode(n).Text())
+				href := ""
+				for _, attr := range n.Attr {
+					if attr.Key == "href" {
+						href = attr.Val
+					}
+				}
+				switch {
+				case href == "" || href == text:
+					b.WriteString(text)
+				case text == "":
+					b.WriteString(href)
+				default:
+					b.WriteString(text + " (" + href + ")")
+				}
+				return
+			case "li":
+				b.WriteString("• ")
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "p", "div", "blockquote", "li", "ul", "ol", "pre", "tr", "h1", "h2", "h3", "h4", "h5", "h6":
+				b.WriteString("\n")
+			}
+		}
+	}
+	for _, node := range doc.Nodes {
+		walk(node)
+	}
+
+	lines := strings.Split(b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(reExtraNewlines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
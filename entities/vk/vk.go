@@ -5,15 +5,14 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-	"path"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	vkapi "github.com/himidori/golang-vk-api"
 	"github.com/n0madic/crossposter"
-	"github.com/n0madic/crossposter/utils"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -50,86 +49,38 @@ func New(entity crossposter.Entity) (crossposter.EntityInterface, error) {
 // Get posts from Vk wall
 func (vk *Vk) Get(lastUpdate time.Time) {
 	defer crossposter.WaitGroup.Done()
+	checkpoints := crossposter.NewCheckpoints(lastUpdate)
 
 	for {
 		for _, domain := range vk.entity.Sources {
 			vkLogger := log.WithFields(log.Fields{"name": domain, "type": vk.entity.Type})
 			vkLogger.Printf("Check wall updates")
-			Items, err := vk.client.WallGet(domain, 10, nil)
+			posts, err := vk.wallPosts(domain, checkpoints.Get(domain))
 			if err != nil {
 				vkLogger.Error(err)
-				return
+				// Publish pages fetched before error
+				if len(posts) == 0 {
+					continue
+				}
 			}
 
-			sort.Slice(Items.Posts, func(i, j int) bool {
-				itime := time.Unix(Items.Posts[i].Date, 0)
-				jtime := time.Unix(Items.Posts[j].Date, 0)
-				return itime.Before(jtime)
+			sort.Slice(posts, func(i, j int) bool {
+				return posts[i].Date < posts[j].Date
 			})
 
-			for _, item := range Items.Posts {
-				if item.MarkedAsAd == 0 {
-					timestamp := time.Unix(item.Date, 0)
-					if timestamp.After(lastUpdate) {
-						lastUpdate = timestamp
-						if item.CopyHistory != nil {
-							item = item.CopyHistory[0]
-						}
-						var photos []string
-						var needMore bool
-						title := ""
-						if item.Attachments != nil {
-							if len(item.Attachments) > 1 {
-								needMore = true
-							}
-							for _, attach := range item.Attachments {
-								switch attach.Type {
-								case "photo":
-									photos = append(photos, getMaxSizePhoto(*attach.Photo))
-								case "video":
-									photos = append(photos, getMaxPreview(*attach.Video))
-									needMore = true
-								case "doc":
-									if attach.Document.Type == 3 { // GIF
-										photos = append(photos, attach.Document.URL)
-										break
-									}
-								case "link":
-									item.Text += "\n" + attach.Link.URL
-									title = attach.Link.Title
-								default:
-									needMore = true
-								}
-							}
-						}
-
-						author, err := vk.getNameFromID(item.FromID)
-						if err != nil {
-							vkLogger.Error(err)
-							return
-						}
-
-						matches := reInternalURLs.FindAllStringSubmatch(item.Text, -1)
-						for _, match := range matches {
-							if strings.HasPrefix(match[1], "club") || strings.HasPrefix(match[1], "id") {
-								match[1] = "https://vk.com/" + match[1]
-							}
-							item.Text = strings.ReplaceAll(item.Text, match[0], fmt.Sprintf("<a href=\"%s\">%s</a>", match[1], match[2]))
-						}
-
-						post := crossposter.Post{
-							Date:        timestamp,
-							URL:         fmt.Sprintf("https://vk.com/wall%v_%v", item.FromID, item.ID),
-							Author:      author,
-							Title:       title,
-							Text:        item.Text,
-							Attachments: photos,
-							More:        needMore,
-						}
-						for _, topic := range vk.entity.Topics {
-							crossposter.Events.Publish(topic, post)
-						}
-					}
+			for _, item := range posts {
+				timestamp := time.Unix(item.Date, 0)
+				if item.MarkedAsAd != 0 || !checkpoints.IsNew(domain, timestamp) {
+					continue
+				}
+				checkpoints.Update(domain, timestamp)
+
+				post, err := vk.wallPostToPost(item)
+				if err != nil {
+					vkLogger.Error(err)
+				}
+				for _, topic := range vk.entity.Topics {
+					crossposter.Events.Publish(topic, post)
 				}
 			}
 		}
@@ -139,6 +90,11 @@ func (vk *Vk) Get(lastUpdate time.Time) {
 
 // Post to Vk
 func (vk *Vk) Post(post crossposter.Post) {
+	err := post.ExtractImages()
+	if err != nil {
+		log.WithFields(log.Fields{"type": vk.entity.Type}).Warnf("Can't extract image: %s", err)
+	}
+
 	for _, destination := range vk.entity.Destinations {
 		var mediaIDs []string
 		vkLogger := log.WithFields(log.Fields{"name": destination, "type": vk.entity.Type})
@@ -146,67 +102,196 @@ func (vk *Vk) Post(post crossposter.Post) {
 		screenName, err := vk.client.ResolveScreenName(destination)
 		if err != nil {
 			vkLogger.Error(err)
-			return
+			continue
 		}
-		if screenName.ObjectID == 0 {
-			vkLogger.Errorf("public %s not found", destination)
+		ownerID := screenName.ObjectID
+		isGroup := screenName.Type != "user"
+		if isGroup {
+			ownerID = -screenName.ObjectID
 		}
 
 		for _, attach := range post.Attachments {
-			filePath := path.Join(os.TempDir(), path.Base(attach))
-			err := utils.DownloadFile(attach, filePath)
-			if err != nil {
-				vkLogger.Error(err)
-				return
-			}
-
-			media, err := vk.client.UploadGroupWallPhotos(screenName.ObjectID, []string{filePath})
-			if err != nil {
-				vkLogger.Error(err)
-				return
-			}
-
-			err = os.Remove(filePath)
+			mediaID, err := vk.uploadAttachment(screenName.ObjectID, attach, post.Title)
 			if err != nil {
-				vkLogger.Error(err)
-				return
+				vkLogger.Errorf("Can't upload %s: %s", attach, err)
+				continue
 			}
-			mediaIDs = append(mediaIDs, vk.client.GetPhotosString(media))
+			mediaIDs = append(mediaIDs, mediaID)
 		}
 
-		message := post.Text
-		if post.More {
-			message += "\n" + post.URL
+		message := htmlToText(post.Text)
+		if post.Title != "" {
+			message = strings.TrimSpace(post.Title + "\n\n" + message)
 		}
+		if post.URL != "" {
+			// Link attachment shows snippet of page
+			mediaIDs = append(mediaIDs, post.URL)
+		}
+
 		params := url.Values{}
 		if len(mediaIDs) > 0 {
 			params.Set("attachments", strings.Join(mediaIDs, ","))
 		}
-		postID, err := vk.client.WallPost(screenName.ObjectID, message, params)
+		if isGroup {
+			if fromGroup, _ := strconv.ParseBool(vk.entity.Option("from_group", destination)); fromGroup {
+				params.Set("from_group", "1")
+			}
+			if signed, _ := strconv.ParseBool(vk.entity.Option("signed", destination)); signed {
+				params.Set("signed", "1")
+			}
+		}
+		if delay := vk.entity.Option("publish_delay", destination); delay != "" {
+			d, err := time.ParseDuration(delay)
+			if err != nil {
+				vkLogger.Errorf("Invalid publish_delay: %s", err)
+			} else {
+				params.Set("publish_date", strconv.FormatInt(time.Now().Add(d).Unix(), 10))
+			}
+		}
+
+		postID, err := vk.client.WallPost(ownerID, message, params)
 		if err != nil {
 			vkLogger.Error(err)
 		} else {
-			vkLogger.Printf("Posted in VK https://vk.com/wall-%v_%v", screenName.ObjectID, postID)
+			vkLogger.Printf("Posted in VK https://vk.com/wall%v_%v", ownerID, postID)
 		}
 	}
 }
 
+// uploadAttachment upload photo, video or document to group and return attachment string
+func (vk *Vk) uploadAttachment(groupID int, attach, title string) (string, error) {
+	filePath, err := download(attach)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(filePath)
+
+	switch attachmentKind(attach) {
+	case "video":
+		return vk.uploadVideo(groupID, filePath, title)
+	case "doc":
+		return vk.uploadDoc(groupID, filePath)
+	}
+	media, err := vk.client.UploadGroupWallPhotos(groupID, []string{filePath})
+	if err != nil {
+		return "", err
+	}
+	return vk.client.GetPhotosString(media), nil
+}
+
 // Handler not implemented
 func (vk *Vk) Handler(w http.ResponseWriter, r *http.Request) {}
 
+// getNameFromID return name of user or group (negative ID)
 func (vk *Vk) getNameFromID(id int) (string, error) {
 	name, ok := userMap.Load(id)
-	if !ok {
-		user, err := vk.client.UsersGet([]int{int(^uint32(id))})
+	if ok {
+		return name.(string), nil
+	}
+	var newname string
+	if id < 0 {
+		groups, err := vk.client.GroupsGetByID([]int{-id})
+		if err != nil {
+			return "", err
+		}
+		if len(groups) == 0 {
+			return "", fmt.Errorf("group %d not found", -id)
+		}
+		newname = groups[0].Name
+	} else {
+		users, err := vk.client.UsersGet([]int{id})
 		if err != nil {
 			return "", err
 		}
-		newname := user[0].FirstName + " " + user[0].LastName
-		if user[0].Nickname != "" {
-			newname += " aka " + user[0].Nickname
+		if len(users) == 0 {
+			return "", fmt.Errorf("user %d not found", id)
+		}
+		newname = userName(users[0])
+	}
+	userMap.Store(id, newname)
+	return newname, nil
+}
+
+// wallPosts return posts of wall newer than checkpoint with pagination
+func (vk *Vk) wallPosts(domain string, checkpoint time.Time) ([]*wallPost, error) {
+	count := defaultPageSize
+	if n, err := strconv.Atoi(vk.entity.Option("count", domain)); err == nil && n > 0 && n <= 100 {
+		count = n
+	}
+	pages := defaultMaxPages
+	if n, err := strconv.Atoi(vk.entity.Option("pages", domain)); err == nil && n > 0 {
+		pages = n
+	}
+
+	var posts []*wallPost
+	for page, offset := 0, 0; page < pages; page++ {
+		w, err := vk.wallGet(domain, count, offset)
+		if err != nil {
+			return posts, err
+		}
+		posts = append(posts, w.Posts...)
+		offset += len(w.Posts)
+
+		reached := len(w.Posts) == 0 || offset >= w.Count
+		for _, item := range w.Posts {
+			// Pinned post may be older than others
+			if item.IsPinned == 0 && !time.Unix(item.Date, 0).After(checkpoint) {
+				reached = true
+			}
+		}
+		if reached {
+			break
+		}
+	}
+	return posts, nil
+}
+
+// wallPostToPost convert wall post with repost to post
+func (vk *Vk) wallPostToPost(item *wallPost) (crossposter.Post, error) {
+	text, media, kinds, title, needMore := item.content()
+	original := item
+	metadata := map[string]string{"id": strconv.Itoa(item.ID)}
+	if len(item.CopyHistory) > 0 {
+		original = item.CopyHistory[0]
+		repostText, repostMedia, repostKinds, repostTitle, repostMore := original.content()
+		if text != "" && repostText != "" {
+			text += "\n\n"
+		}
+		text += repostText
+		media = append(media, repostMedia...)
+		kinds = append(kinds, repostKinds...)
+		if title == "" {
+			title = repostTitle
 		}
-		userMap.Store(id, strings.TrimSpace(newname))
-		return newname, nil
+		needMore = needMore || repostMore
+		metadata["repost_url"] = item.postURL()
+		if reposter, err := vk.getNameFromID(item.FromID); err == nil {
+			metadata["reposted_by"] = reposter
+		}
+	}
+
+	matches := reInternalURLs.FindAllStringSubmatch(text, -1)
+	for _, match := range matches {
+		if strings.HasPrefix(match[1], "club") || strings.HasPrefix(match[1], "id") {
+			match[1] = "https://vk.com/" + match[1]
+		}
+		text = strings.ReplaceAll(text, match[0], fmt.Sprintf("<a href=\"%s\">%s</a>", match[1], match[2]))
+	}
+
+	if len(kinds) > 0 {
+		metadata["media_types"] = strings.Join(kinds, ",")
+	}
+
+	post := crossposter.Post{
+		Date:        time.Unix(item.Date, 0),
+		URL:         original.postURL(),
+		Title:       title,
+		Text:        text,
+		Attachments: media,
+		More:        needMore,
+		Metadata:    metadata,
 	}
-	return name.(string), nil
+	author, err := vk.getNameFromID(original.FromID)
+	post.Author = author
+	return post, err
 }
@@ -0,0 +1,166 @@
+package vk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	vkapi "github.com/himidori/golang-vk-api"
+)
+
+const (
+	defaultPageSize = 20
+	defaultMaxPages = 5
+)
+
+// wall page with authors of posts
+type wall struct {
+	Count    int            `json:"count"`
+	Posts    []*wallPost    `json:"items"`
+	Profiles []*vkapi.User  `json:"profiles"`
+	Groups   []*vkapi.Group `json:"groups"`
+}
+
+// wallPost with attachments missing in library types
+type wallPost struct {
+	ID          int           `json:"id"`
+	FromID      int           `json:"from_id"`
+	OwnerID     int           `json:"owner_id"`
+	Date        int64         `json:"date"`
+	MarkedAsAd  int           `json:"marked_as_ads"`
+	IsPinned    int           `json:"is_pinned"`
+	Text        string        `json:"text"`
+	SignerID    int           `json:"signer_id"`
+	CopyHistory []*wallPost   `json:"copy_history"`
+	Attachments []*attachment `json:"attachments"`
+}
+
+type attachment struct {
+	vkapi.MessageAttachment
+	Poll    *poll    `json:"poll"`
+	Article *article `json:"article"`
+}
+
+type poll struct {
+	Question string `json:"question"`
+	Answers  []struct {
+		Text string `json:"text"`
+	} `json:"answers"`
+}
+
+type article struct {
+	Title string                 `json:"title"`
+	URL   string                 `json:"url"`
+	Photo *vkapi.PhotoAttachment `json:"photo"`
+}
+
+// wallGet return page of wall posts with authors
+func (vk *Vk) wallGet(domain string, count, offset int) (*wall, error) {
+	params := url.Values{}
+	params.Set("domain", domain)
+	params.Set("count", strconv.Itoa(count))
+	params.Set("offset", strconv.Itoa(offset))
+	params.Set("extended", "1")
+
+	resp, err := vk.client.MakeRequest("wall.get", params)
+	if err != nil {
+		return nil, err
+	}
+	var w wall
+	err = json.Unmarshal(resp.Response, &w)
+	if err != nil {
+		return nil, err
+	}
+	for _, profile := range w.Profiles {
+		userMap.Store(profile.UID, userName(profile))
+	}
+	for _, group := range w.Groups {
+		userMap.Store(-group.ID, group.Name)
+	}
+	return &w, nil
+}
+
+// postURL return link to wall post
+func (p *wallPost) postURL() string {
+	ownerID := p.OwnerID
+	if ownerID == 0 {
+		ownerID = p.FromID
+	}
+	return fmt.Sprintf("https://vk.com/wall%v_%v", ownerID, p.ID)
+}
+
+// content return text and media with their kinds of post attachments
+func (p *wallPost) content() (text string, media, kinds []string, title string, needMore bool) {
+	var lines []string
+	if p.Text != "" {
+		lines = append(lines, p.Text)
+	}
+	if len(p.Attachments) > 1 {
+		needMore = true
+	}
+	addMedia := func(link, kind string) {
+		media = append(media, link)
+		kinds = append(kinds, kind)
+	}
+	for _, attach := range p.Attachments {
+		switch attach.Type {
+		case "photo":
+			if attach.Photo != nil {
+				addMedia(getMaxSizePhoto(*attach.Photo), "photo")
+			}
+		case "video":
+			if attach.Video != nil {
+				addMedia(getMaxPreview(*attach.Video), "photo")
+				lines = append(lines, fmt.Sprintf(`<a href="https://vk.com/video%v_%v">%s</a>`,
+					attach.Video.OwnerID, attach.Video.ID, attach.Video.Title))
+			}
+			needMore = true
+		case "doc":
+			if attach.Document == nil {
+				break
+			}
+			switch {
+			case attach.Document.URL == "":
+				lines = append(lines, attach.Document.Title)
+			case attach.Document.Type == 3: // GIF
+				addMedia(attach.Document.URL, "animation")
+			default:
+				addMedia(attach.Document.URL, "document")
+			}
+		case "audio":
+			if attach.Audio != nil {
+				lines = append(lines, strings.TrimSpace("♫ "+attach.Audio.Artist+" — "+attach.Audio.Title))
+				// Audio URL is empty when access to it is restricted
+				if attach.Audio.URL != "" {
+					addMedia(attach.Audio.URL, "audio")
+				}
+			}
+		case "poll":
+			if attach.Poll != nil {
+				answers := make([]string, 0, len(attach.Poll.Answers))
+				for _, answer := range attach.Poll.Answers {
+					answers = append(answers, "• "+answer.Text)
+				}
+				lines = append(lines, attach.Poll.Question+"\n"+strings.Join(answers, "\n"))
+			}
+		case "article":
+			if attach.Article != nil {
+				lines = append(lines, fmt.Sprintf(`<a href="%s">%s</a>`, attach.Article.URL, attach.Article.Title))
+				if attach.Article.Photo != nil {
+					addMedia(getMaxSizePhoto(*attach.Article.Photo), "photo")
+				}
+				title = attach.Article.Title
+			}
+		case "link":
+			if attach.Link != nil {
+				lines = append(lines, attach.Link.URL)
+				title = attach.Link.Title
+			}
+		default:
+			needMore = true
+		}
+	}
+	return strings.Join(lines, "\n"), media, kinds, title, needMore
+}
@@ -0,0 +1,388 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/n0madic/crossposter"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultTimeout         = 10
+	defaultRetries         = 3
+	defaultSignatureHeader = "X-Signature-256"
+	headerOptionPrefix     = "header_"
+	maxBodySize            = 1 << 20
+	maxRetryAfter          = 5 * time.Minute
+	queueSize              = 100
+)
+
+// Webhook entity
+type Webhook struct {
+	entity          *crossposter.Entity
+	client          *http.Client
+	method          string
+	contentType     string
+	headers         map[string]string
+	template        *template.Template
+	secret          string
+	signatureHeader string
+	retries         int
+	token           string
+	insecure        bool
+	posts           chan crossposter.Post
+}
+
+// Paths of handlers registered by webhook entities
+var hookPaths = make(map[string]bool)
+
+func init() {
+	crossposter.AddEntity("webhook", New)
+}
+
+// New return webhook entity
+func New(entity crossposter.Entity) (crossposter.EntityInterface, error) {
+	hook := &Webhook{
+		entity:          &entity,
+		method:          strings.ToUpper(entity.Options["method"]),
+		contentType:     entity.Options["content_type"],
+		headers:         make(map[string]string),
+		secret:          entity.Options["secret"],
+		signatureHeader: entity.Options["signature_header"],
+		retries:         defaultRetries,
+		token:           entity.Options["token"],
+		posts:           make(chan crossposter.Post, queueSize),
+	}
+	switch hook.method {
+	case "":
+		hook.method = http.MethodPost
+	case http.MethodPost, http.MethodPut:
+	default:
+		return nil, fmt.Errorf("unsupported webhook method: %s", hook.method)
+	}
+	if hook.contentType == "" {
+		hook.contentType = "application/json"
+	}
+	if hook.signatureHeader == "" {
+		hook.signatureHeader = defaultSignatureHeader
+	}
+
+	timeout := defaultTimeout
+	if entity.Options["timeout"] != "" {
+		t, err := strconv.Atoi(entity.Options["timeout"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook timeout: %v", err)
+		}
+		timeout = t
+	}
+	hook.client = &http.Client{Timeout: time.Duration(timeout) * time.Second}
+
+	if entity.Options["retries"] != "" {
+		r, err := strconv.Atoi(entity.Options["retries"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook retries: %v", err)
+		}
+		hook.retries = r
+	}
+
+	for key, value := range entity.Options {
+		if strings.HasPrefix(key, headerOptionPrefix) {
+			hook.headers[strings.TrimPrefix(key, headerOptionPrefix)] = value
+		}
+	}
+
+	tpl := entity.Options["template"]
+	if file := entity.Options["template_file"]; file != "" {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("can't read webhook template: %v", err)
+		}
+		tpl = string(content)
+	}
+	if tpl != "" {
+		t, err := template.New("webhook").Funcs(template.FuncMap{"json": toJSON}).Parse(tpl)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse webhook template: %v", err)
+		}
+		hook.template = t
+	}
+
+	if len(entity.Sources) > 0 && hook.token == "" && hook.secret == "" {
+		if insecure, _ := strconv.ParseBool(entity.Options["insecure"]); !insecure {
+			return nil, fmt.Errorf("webhook producer requires token or secret, set insecure option to accept any push")
+		}
+		hook.insecure = true
+	}
+	paths := make(map[string]bool)
+	for _, source := range entity.Sources {
+		path := "/hook/" + source
+		if hookPaths[path] || paths[path] {
+			return nil, fmt.Errorf("webhook path %s already used", path)
+		}
+		paths[path] = true
+	}
+	for path := range paths {
+		hookPaths[path] = true
+		http.HandleFunc(path, hook.Handler)
+	}
+
+	return hook, nil
+}
+
+// Get posts pushed to webhook
+func (hook *Webhook) Get(lastUpdate time.Time) {
+	defer crossposter.WaitGroup.Done()
+
+	log.WithFields(log.Fields{"sources": hook.entity.Sources, "type": hook.entity.Type}).Println("Wait for pushes")
+	for post := range hook.posts {
+		for _, topic := range hook.entity.Topics {
+			crossposter.Events.Publish(topic, post)
+		}
+	}
+}
+
+// Post send post to webhook destinations
+func (hook *Webhook) Post(post crossposter.Post) {
+	body, err := hook.render(post)
+	if err != nil {
+		log.WithFields(log.Fields{"type": hook.entity.Type}).Error(err)
+		return
+	}
+
+	for _, destination := range hook.entity.Destinations {
+		hookLogger := log.WithFields(log.Fields{"url": destination, "type": hook.entity.Type})
+
+		for attempt := 0; ; attempt++ {
+			retry, wait, err := hook.send(destination, body)
+			if err == nil {
+				hookLogger.Printf("Posted %s", post.URL)
+				break
+			}
+			if !retry || attempt >= hook.retries {
+				hookLogger.Error(err)
+				break
+			}
+			if wait == 0 {
+				wait = time.Duration(1<<attempt) * time.Second
+			}
+			if limit := hook.maxWait(); wait > limit {
+				wait = limit
+			}
+			hookLogger.Warnf("%s, retry in %v", err, wait)
+			time.Sleep(wait)
+		}
+	}
+}
+
+// maxWait return longest delay before retry, entity wait if set
+func (hook *Webhook) maxWait() time.Duration {
+	if hook.entity.Wait > 0 {
+		return time.Duration(hook.entity.Wait) * time.Minute
+	}
+	return maxRetryAfter
+}
+
+// Handler accept post pushed to webhook
+func (hook *Webhook) Handler(w http.ResponseWriter, r *http.Request) {
+	hookLogger := log.WithFields(log.Fields{"path": r.URL.Path, "type": hook.entity.Type})
+
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		w.Header().Set("Allow", "POST, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !hook.authorized(r, body) {
+		hookLogger.Warnf("Unauthorized push from %s", r.RemoteAddr)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	post, err := parsePost(r.Header.Get("Content-Type"), body)
+	if err != nil {
+		hookLogger.Warn(err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	select {
+	case hook.posts <- post:
+		hookLogger.Printf("Accepted post %s", post.URL)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		hookLogger.Error("Queue of pushed posts is full")
+		http.Error(w, "queue is full", http.StatusServiceUnavailable)
+	}
+}
+
+// authorized check shared token or HMAC signature of request
+func (hook *Webhook) authorized(r *http.Request, body []byte) bool {
+	if hook.insecure {
+		return true
+	}
+	if hook.token != "" {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+		if hmac.Equal([]byte(token), []byte(hook.token)) {
+			return true
+		}
+	}
+	if hook.secret != "" {
+		signature := strings.TrimPrefix(r.Header.Get(hook.signatureHeader), "sha256=")
+		if hmac.Equal([]byte(signature), []byte(sign(body, hook.secret))) {
+			return true
+		}
+	}
+	return false
+}
+
+// render request body from template or default post serialization
+func (hook *Webhook) render(post crossposter.Post) ([]byte, error) {
+	if hook.template == nil {
+		return json.Marshal(post)
+	}
+	var buf bytes.Buffer
+	err := hook.template.Execute(&buf, &post)
+	if err != nil {
+		return nil, fmt.Errorf("can't render webhook template: %v", err)
+	}
+	if strings.Contains(hook.contentType, "json") && !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("webhook template rendered invalid JSON")
+	}
+	return buf.Bytes(), nil
+}
+
+// send body to URL, returns whether a failed request can be retried
+// and how long the server asked to wait before retry
+func (hook *Webhook) send(url string, body []byte) (bool, time.Duration, error) {
+	req, err := http.NewRequest(hook.method, url, bytes.NewReader(body))
+	if err != nil {
+		return false, 0, err
+	}
+	req.Header.Set("Content-Type", hook.contentType)
+	req.Header.Set("User-Agent", "Crossposter/1.0")
+	for key, value := range hook.headers {
+		req.Header.Set(key, value)
+	}
+	if hook.secret != "" {
+		req.Header.Set(hook.signatureHeader, "sha256="+sign(body, hook.secret))
+	}
+
+	res, err := hook.client.Do(req)
+	if err != nil {
+		return true, 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		io.Copy(ioutil.Discard, res.Body)
+		return false, 0, nil
+	}
+
+	content, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+	err = fmt.Errorf("bad status: %s %s", res.Status, strings.TrimSpace(string(content)))
+	return retryable(res.StatusCode), retryAfter(res.Header.Get("Retry-After")), err
+}
+
+// sign body with HMAC-SHA256 and return hex digest
+func sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryable check if request with response status may succeed later
+func retryable(status int) bool {
+	switch {
+	case status == http.StatusRequestTimeout, status == http.StatusTooManyRequests:
+		return true
+	case status >= 500 && status != http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+// retryAfter parse Retry-After header value
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+// parsePost from JSON or form encoded body
+func parsePost(contentType string, body []byte) (crossposter.Post, error) {
+	var post crossposter.Post
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return post, err
+		}
+		post.URL = form.Get("url")
+		post.Author = form.Get("author")
+		post.Title = form.Get("title")
+		post.Text = form.Get("text")
+		post.Attachments = form["attachments"]
+		post.More, _ = strconv.ParseBool(form.Get("more"))
+		if date := form.Get("date"); date != "" {
+			post.Date, err = parseDate(date)
+			if err != nil {
+				return post, err
+			}
+		}
+	} else {
+		err := json.Unmarshal(body, &post)
+		if err != nil {
+			return post, fmt.Errorf("can't parse post: %v", err)
+		}
+	}
+	// Metadata is internal to producers and never accepted from outside
+	post.Metadata = nil
+	if post.Title == "" && post.Text == "" && post.URL == "" && len(post.Attachments) == 0 {
+		return post, fmt.Errorf("empty post")
+	}
+	if post.Date.IsZero() {
+		post.Date = time.Now()
+	}
+	return post, nil
+}
+
+// parseDate in RFC3339 or unix timestamp format
+func parseDate(value string) (time.Time, error) {
+	if timestamp, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(timestamp, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
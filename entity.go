@@ -16,6 +16,8 @@ type (
 		Destinations []string          `json:"destinations" yaml:"destinations"`
 		Topics       []string          `json:"topics" yaml:"topics"`
 		Wait         int               `json:"wait" yaml:"wait"`
+		// Overrides of options for particular sources or destinations
+		Overrides map[string]map[string]string `json:"overrides" yaml:"overrides"`
 	}
 
 	// EntityInterface is interface
@@ -41,3 +43,12 @@ func AddEntity(name string, init Initializer) {
 		Initializers[name] = init
 	}
 }
+
+// Option return value of option for source or destination
+// with fallback to common entity options
+func (entity *Entity) Option(name, target string) string {
+	if value, ok := entity.Overrides[target][name]; ok {
+		return value
+	}
+	return entity.Options[name]
+}
@@ -11,13 +11,15 @@ import (
 
 // Post data struct
 type Post struct {
-	Date        time.Time
-	URL         string
-	Author      string
-	Title       string
-	Text        string
-	Attachments []string
-	More        bool
+	Date        time.Time `json:"date"`
+	URL         string    `json:"url"`
+	Author      string    `json:"author"`
+	Title       string    `json:"title"`
+	Text        string    `json:"text"`
+	Attachments []string  `json:"attachments"`
+	More        bool      `json:"more"`
+	// Metadata of post specific for source service
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 // ExtractImages from HTML to attachments
@@ -0,0 +1,47 @@
+package crossposter
+
+import (
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// Published registry of items already seen by producer
+type Published struct {
+	cache       *lru.Cache
+	lastUpdate  time.Time
+	initialized map[string]bool
+}
+
+// NewPublished return registry of published items with limited size
+func NewPublished(size int, lastUpdate time.Time) (*Published, error) {
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &Published{
+		cache:       cache,
+		lastUpdate:  lastUpdate,
+		initialized: make(map[string]bool),
+	}, nil
+}
+
+// IsNew mark item as seen and report if it must be published.
+// On first check of source only items dated after last update are new,
+// later any unseen item is new whatever its date is.
+// Zero date means that item has no date.
+func (p *Published) IsNew(source, id string, date time.Time) bool {
+	if p.cache.Contains(id) {
+		return false
+	}
+	p.cache.Add(id, nil)
+	if p.initialized[source] {
+		return true
+	}
+	return !date.IsZero() && date.After(p.lastUpdate)
+}
+
+// Initialize mark first check of source as completed
+func (p *Published) Initialize(source string) {
+	p.initialized[source] = true
+}
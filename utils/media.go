@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// mediaTypes of common extensions missing in builtin MIME table of minimal images
+var mediaTypes = map[string]string{
+	".gif":  "image/gif",
+	".jpeg": "image/jpeg",
+	".jpg":  "image/jpeg",
+	".png":  "image/png",
+	".webp": "image/webp",
+	".avi":  "video/x-msvideo",
+	".m4v":  "video/x-m4v",
+	".mkv":  "video/x-matroska",
+	".mov":  "video/quicktime",
+	".mp4":  "video/mp4",
+	".webm": "video/webm",
+	".m4a":  "audio/mp4",
+	".mp3":  "audio/mpeg",
+	".ogg":  "audio/ogg",
+}
+
+// MediaType detect media type of URL by file extension
+// with fallback to Content-Type of HEAD request
+func MediaType(rawurl string) string {
+	ext := ""
+	if u, err := url.Parse(rawurl); err == nil {
+		ext = strings.ToLower(path.Ext(u.Path))
+	}
+	mediaType, ok := mediaTypes[ext]
+	if !ok && ext != "" {
+		mediaType = mime.TypeByExtension(ext)
+	}
+	if mediaType == "" && IsRequestURL(rawurl) {
+		res, err := httpClient.Head(rawurl)
+		if err == nil {
+			res.Body.Close()
+			mediaType = res.Header.Get("Content-Type")
+		}
+	}
+	mediaType, _, _ = mime.ParseMediaType(mediaType)
+	return mediaType
+}
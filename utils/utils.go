@@ -64,11 +64,19 @@ func DownloadFile(url string, filePath string) error {
 
 // GetJSON from URL
 func GetJSON(url string, target interface{}) error {
+	return GetJSONWithHeaders(url, nil, target)
+}
+
+// GetJSONWithHeaders from URL with additional request headers
+func GetJSONWithHeaders(url string, headers map[string]string, target interface{}) error {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return err
 	}
 	req.Header.Set("User-Agent", "Crossposter/1.0")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
 
 	r, err := httpClient.Do(req)
 	if err != nil {
@@ -81,7 +89,10 @@ func GetJSON(url string, target interface{}) error {
 		return fmt.Errorf(string(content))
 	}
 
-	return json.NewDecoder(r.Body).Decode(target)
+	// Keep numbers as json.Number to not lose precision of big IDs
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	return decoder.Decode(target)
 }
 
 // GetURLContentInBase64 get content from URL and return it in base64
@@ -140,3 +151,14 @@ func StringInSlice(a string, list []string) bool {
 	}
 	return false
 }
+
+// WriteFileAtomic write data to temporary file and rename it,
+// so file is never left partially written
+func WriteFileAtomic(filePath string, data []byte, perm os.FileMode) error {
+	tmpFile := filePath + ".tmp"
+	err := ioutil.WriteFile(tmpFile, data, perm)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, filePath)
+}